@@ -0,0 +1,200 @@
+// Package blend has canvases that sketches can draw into with blend modes other than source over
+// Colors are kept at 16 bits per channel, or as floats for high dynamic range, so many faint strokes add up instead of rounding away.
+package blend
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// Mode is a way of combining a color with what's already on the canvas
+type Mode int
+
+// The blend modes, each is mixed with the canvas by the stroke's alpha
+const (
+	// Normal paints over the canvas
+	Normal Mode = iota
+	// Add adds light, so overlaps brighten towards white
+	Add
+	// Screen brightens like Add but eases off towards white
+	Screen
+	// Multiply darkens, like layers of ink
+	Multiply
+	// Lighten keeps the brighter of the canvas and the color
+	Lighten
+)
+
+// Modes are the names of the blend modes, in the order of their values
+var Modes = []string{"normal", "add", "screen", "multiply", "lighten"}
+
+// ParseMode finds the mode with a name from Modes, an empty name is Normal
+func ParseMode(name string) (Mode, error) {
+	if name == "" {
+		return Normal, nil
+	}
+	for i, m := range Modes {
+		if m == name {
+			return Mode(i), nil
+		}
+	}
+	return Normal, fmt.Errorf("unknown blend mode %q, use one of %v", name, Modes)
+}
+
+func (m Mode) String() string {
+	if m < 0 || int(m) >= len(Modes) {
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+	return Modes[m]
+}
+
+// mix combines a canvas value d with a color value s, both between 0 and 1
+func (m Mode) mix(d, s float64) float64 {
+	switch m {
+	case Add:
+		return d + s
+	case Screen:
+		return 1 - (1-d)*(1-s)
+	case Multiply:
+		return d * s
+	case Lighten:
+		return math.Max(d, s)
+	}
+	return s
+}
+
+// Surface is a canvas that colors can be blended into a pixel at a time
+type Surface interface {
+	// Blend combines a 0-255 color with one pixel, pixels off the canvas are ignored
+	Blend(x, y int, c [3]int, alpha float64, mode Mode)
+	// Image converts the canvas to 8 bits per channel
+	Image() *image.RGBA
+	// Bounds is the size of the canvas
+	Bounds() image.Rectangle
+}
+
+// Buffer is an opaque canvas with 16 bits for each of red, green and blue
+type Buffer struct {
+	Width  int
+	Height int
+	// Pix holds the red, green and blue of each pixel in rows from the top left
+	Pix []uint16
+}
+
+// NewBuffer creates a canvas filled with a 0-255 background color
+func NewBuffer(width, height int, background [3]int) *Buffer {
+	b := &Buffer{Width: width, Height: height, Pix: make([]uint16, 3*width*height)}
+	for i := 0; i < len(b.Pix); i += 3 {
+		for c := 0; c < 3; c++ {
+			b.Pix[i+c] = uint16(background[c] * 257)
+		}
+	}
+	return b
+}
+
+// Blend combines a 0-255 color with one pixel, pixels off the canvas are ignored
+func (b *Buffer) Blend(x, y int, c [3]int, alpha float64, mode Mode) {
+	// written as not more than 0 so a NaN alpha is skipped too
+	if x < 0 || y < 0 || x >= b.Width || y >= b.Height || !(alpha > 0) {
+		return
+	}
+	if alpha > 1 {
+		alpha = 1
+	}
+	p := b.Pix[3*(y*b.Width+x):]
+	for k := 0; k < 3; k++ {
+		d := float64(p[k]) / 65535
+		v := d + (mode.mix(d, float64(c[k])/255)-d)*alpha
+		p[k] = uint16(clamp(v)*65535 + 0.5)
+	}
+}
+
+// finite is false for NaN and the infinities
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// clamp holds v between 0 and 1, it's on every pixel blended so it compares directly rather than calling math.Max and math.Min
+func clamp(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// Bounds is the size of the canvas
+func (b *Buffer) Bounds() image.Rectangle {
+	return image.Rect(0, 0, b.Width, b.Height)
+}
+
+// Splat blends a color at a point between pixels, sharing alpha between the four pixels around it by how close each pixel center is
+// A splat at a point or alpha that isn't a finite number is dropped, there's no pixel it could belong to.
+func Splat(b Surface, x, y float64, c [3]int, alpha float64, mode Mode) {
+	if !finite(x) || !finite(y) || !finite(alpha) {
+		return
+	}
+	// pixel centers sit on the half pixel
+	fx := x - 0.5
+	fy := y - 0.5
+	x0 := int(math.Floor(fx))
+	y0 := int(math.Floor(fy))
+	dx := fx - float64(x0)
+	dy := fy - float64(y0)
+
+	b.Blend(x0, y0, c, alpha*(1-dx)*(1-dy), mode)
+	b.Blend(x0+1, y0, c, alpha*dx*(1-dy), mode)
+	b.Blend(x0, y0+1, c, alpha*(1-dx)*dy, mode)
+	b.Blend(x0+1, y0+1, c, alpha*dx*dy, mode)
+}
+
+// Line blends a one pixel wide line from x0, y0 to x1, y1, leaving out the starting point so joined lines don't double up
+// Every pixel along the line gets about alpha in total.
+func Line(b Surface, x0, y0, x1, y1 float64, c [3]int, alpha float64, mode Mode) {
+	d := math.Hypot(x1-x0, y1-y0)
+	steps := int(math.Ceil(d * 2))
+	if steps < 1 {
+		return
+	}
+	a := alpha * d / float64(steps)
+	for k := 1; k <= steps; k++ {
+		t := float64(k) / float64(steps)
+		Splat(b, x0+(x1-x0)*t, y0+(y1-y0)*t, c, a, mode)
+	}
+}
+
+// Draw blends an image over the canvas, using the image's alpha, the image's top left lands on the canvas' top left
+func Draw(b Surface, img image.Image, mode Mode) {
+	bounds := img.Bounds()
+	size := b.Bounds()
+	for y := 0; y < size.Dy() && y < bounds.Dy(); y++ {
+		for x := 0; x < size.Dx() && x < bounds.Dx(); x++ {
+			r, g, bl, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			if a == 0 {
+				continue
+			}
+			// colors from RGBA are premultiplied by alpha
+			alpha := float64(a) / 65535
+			c := [3]int{
+				int(float64(r) / float64(a) * 255),
+				int(float64(g) / float64(a) * 255),
+				int(float64(bl) / float64(a) * 255),
+			}
+			b.Blend(x, y, c, alpha, mode)
+		}
+	}
+}
+
+// Image converts the canvas to 8 bits per channel, rounding to the nearest value
+func (b *Buffer) Image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, b.Width, b.Height))
+	for i, j := 0, 0; i < len(b.Pix); i, j = i+3, j+4 {
+		img.Pix[j] = uint8((uint32(b.Pix[i]) + 128) / 257)
+		img.Pix[j+1] = uint8((uint32(b.Pix[i+1]) + 128) / 257)
+		img.Pix[j+2] = uint8((uint32(b.Pix[i+2]) + 128) / 257)
+		img.Pix[j+3] = 255
+	}
+	return img
+}
@@ -0,0 +1,111 @@
+package blend
+
+import (
+	"math"
+	"testing"
+)
+
+func TestModes(t *testing.T) {
+	tests := []struct {
+		mode Mode
+		want uint8
+	}{
+		{Normal, 200},
+		{Add, 255},
+		{Screen, 222},
+		{Multiply, 78},
+		{Lighten, 200},
+	}
+	for _, tt := range tests {
+		b := NewBuffer(1, 1, [3]int{100, 100, 100})
+		b.Blend(0, 0, [3]int{200, 200, 200}, 1, tt.mode)
+		if got := b.Image().Pix[0]; got != tt.want {
+			t.Errorf("%v: got %d, want %d", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestFaintStrokesAddUp(t *testing.T) {
+	// a thousandth of white is well under one step of 8 bit color, but a thousand of them make white
+	b := NewBuffer(1, 1, [3]int{0, 0, 0})
+	for i := 0; i < 1000; i++ {
+		b.Blend(0, 0, [3]int{255, 255, 255}, 0.001, Add)
+	}
+	if got := b.Image().Pix[0]; got < 254 {
+		t.Errorf("got %d, want white", got)
+	}
+}
+
+func TestLineCoversEachPixelOnce(t *testing.T) {
+	b := NewBuffer(20, 3, [3]int{0, 0, 0})
+	Line(b, 0, 1.5, 20, 1.5, [3]int{255, 255, 255}, 0.5, Add)
+	img := b.Image()
+	for x := 1; x < 19; x++ {
+		if got := img.Pix[img.PixOffset(x, 1)]; got < 120 || got > 135 {
+			t.Errorf("pixel %d is %d, want about half", x, got)
+		}
+	}
+}
+
+func TestSplatDropsPointsThatArentNumbers(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	for _, b := range []Surface{NewBuffer(2, 2, [3]int{0, 0, 0}), NewHDRBuffer(2, 2, [3]int{0, 0, 0}, 0, 1)} {
+		for _, p := range [][3]float64{{nan, 1, 1}, {1, nan, 1}, {1, 1, nan}, {inf, 1, 1}, {1, -inf, 1}, {1, 1, inf}} {
+			Splat(b, p[0], p[1], [3]int{255, 255, 255}, p[2], Add)
+		}
+		// a NaN alpha straight to a pixel is skipped too
+		b.Blend(0, 0, [3]int{255, 255, 255}, nan, Add)
+		for i, v := range b.Image().Pix {
+			if i%4 != 3 && v != 0 {
+				t.Fatalf("%T: pixel byte %d is %d, want the canvas untouched", b, i, v)
+			}
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for i, name := range Modes {
+		m, err := ParseMode(name)
+		if err != nil || m != Mode(i) || m.String() != name {
+			t.Errorf("%s: got %v, %v", name, m, err)
+		}
+	}
+	if _, err := ParseMode("overlay"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestHDRKeepsLightPastWhite(t *testing.T) {
+	b := NewHDRBuffer(1, 1, [3]int{0, 0, 0}, 0, 1)
+	for i := 0; i < 4; i++ {
+		b.Blend(0, 0, [3]int{255, 128, 0}, 1, Add)
+	}
+	if got := b.Image().Pix[:3]; got[0] != 255 || got[1] != 255 || got[2] != 0 {
+		t.Errorf("at exposure 0 got %v, want clipped at white", got)
+	}
+	// four times the light, two stops down, is back to the color
+	b.Exposure = -2
+	if got := b.Image().Pix[:3]; got[0] != 255 || got[1] < 127 || got[1] > 129 || got[2] != 0 {
+		t.Errorf("at exposure -2 got %v, want the color", got)
+	}
+}
+
+func TestHDRGammaLiftsShadows(t *testing.T) {
+	b := NewHDRBuffer(1, 1, [3]int{64, 64, 64}, 0, 2)
+	if got := b.Image().Pix[0]; got < 127 || got > 129 {
+		t.Errorf("got %d, want about half", got)
+	}
+}
+
+func TestSurfacesMatchInRange(t *testing.T) {
+	surfaces := []Surface{NewBuffer(4, 1, [3]int{255, 255, 255}), NewHDRBuffer(4, 1, [3]int{255, 255, 255}, 0, 1)}
+	for _, s := range surfaces {
+		Line(s, 0, 0.5, 4, 0.5, [3]int{30, 60, 90}, 0.7, Normal)
+	}
+	a, b := surfaces[0].Image().Pix, surfaces[1].Image().Pix
+	for i := range a {
+		if d := int(a[i]) - int(b[i]); d < -1 || d > 1 {
+			t.Fatalf("byte %d differs: %d and %d", i, a[i], b[i])
+		}
+	}
+}
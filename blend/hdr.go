@@ -0,0 +1,73 @@
+package blend
+
+import (
+	"image"
+	"math"
+)
+
+// HDRBuffer is an opaque canvas of floats, where 1 is full brightness and light can keep adding up past it
+// Image brings the canvas back into range with an exposure and gamma, like developing a long exposure.
+type HDRBuffer struct {
+	Width  int
+	Height int
+	// Pix holds the red, green and blue of each pixel in rows from the top left
+	Pix []float32
+	// Exposure brightens the image by powers of two when it is converted, negative values darken it
+	Exposure float64
+	// Gamma above 1 lifts the shadows when the image is converted, 1 leaves them as they are
+	Gamma float64
+}
+
+// NewHDRBuffer creates a canvas filled with a 0-255 background color
+func NewHDRBuffer(width, height int, background [3]int, exposure, gamma float64) *HDRBuffer {
+	b := &HDRBuffer{Width: width, Height: height, Pix: make([]float32, 3*width*height), Exposure: exposure, Gamma: gamma}
+	for i := 0; i < len(b.Pix); i += 3 {
+		for c := 0; c < 3; c++ {
+			b.Pix[i+c] = float32(background[c]) / 255
+		}
+	}
+	return b
+}
+
+// Blend combines a 0-255 color with one pixel, pixels off the canvas are ignored
+// Values aren't clamped, so adding light past white is kept for the exposure to bring back.
+func (b *HDRBuffer) Blend(x, y int, c [3]int, alpha float64, mode Mode) {
+	// written as not more than 0 so a NaN alpha is skipped too
+	if x < 0 || y < 0 || x >= b.Width || y >= b.Height || !(alpha > 0) {
+		return
+	}
+	if alpha > 1 {
+		alpha = 1
+	}
+	p := b.Pix[3*(y*b.Width+x):]
+	for k := 0; k < 3; k++ {
+		d := float64(p[k])
+		p[k] = float32(math.Max(0, d+(mode.mix(d, float64(c[k])/255)-d)*alpha))
+	}
+}
+
+// Bounds is the size of the canvas
+func (b *HDRBuffer) Bounds() image.Rectangle {
+	return image.Rect(0, 0, b.Width, b.Height)
+}
+
+// Image converts the canvas to 8 bits per channel, scaling by the exposure, clipping at white, then applying the gamma
+func (b *HDRBuffer) Image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, b.Width, b.Height))
+	scale := math.Exp2(b.Exposure)
+	gamma := b.Gamma
+	if gamma <= 0 {
+		gamma = 1
+	}
+	for i, j := 0, 0; i < len(b.Pix); i, j = i+3, j+4 {
+		for k := 0; k < 3; k++ {
+			v := math.Min(float64(b.Pix[i+k])*scale, 1)
+			if gamma != 1 {
+				v = math.Pow(v, 1/gamma)
+			}
+			img.Pix[j+k] = uint8(v*255 + 0.5)
+		}
+		img.Pix[j+3] = 255
+	}
+	return img
+}
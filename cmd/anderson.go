@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/spf13/cobra"
@@ -11,28 +13,62 @@ import (
 	"gitlab.com/ericworkman/generative/util"
 )
 
+var (
+	andersonSlots         = 0
+	andersonLeftEdge      = 1.0
+	andersonRightEdge     = 1.0
+	andersonHorizon       = 0.0
+	andersonPalette       = ""
+	andersonWaterScale    = 1.4
+	andersonRippleSpacing = 100
+	andersonPainterly     = false
+	andersonWaveAmplitude = 6.0
+	andersonWaveLength    = 40.0
+	andersonBlur          = 4
+)
+
 // andersonCmd represents the anderson command
 var andersonCmd = &cobra.Command{
 	Use:   "anderson",
 	Short: "Create art based on Jason Anderson's work",
 	Long:  ``,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("anderson called")
+		palette, err := parsePalette(andersonPalette)
+		if err != nil {
+			return err
+		}
 		params := sketch.AndersonParams{
-			DestWidth:  width,
-			DestHeight: height,
-			Iterations: limitByIterations,
+			DestWidth:      width,
+			DestHeight:     height,
+			Iterations:     limitByIterations,
+			Slots:          andersonSlots,
+			LeftEdge:       andersonLeftEdge,
+			RightEdge:      andersonRightEdge,
+			Horizon:        andersonHorizon,
+			Palette:        palette,
+			WaterScale:     andersonWaterScale,
+			RippleSpacing:  andersonRippleSpacing,
+			Painterly:      andersonPainterly,
+			WaveAmplitude:  andersonWaveAmplitude,
+			WaveLength:     andersonWaveLength,
+			ReflectionBlur: andersonBlur,
+			Random:         random(),
+		}
+		if err := params.Validate(); err != nil {
+			return err
 		}
 
 		csketch := sketch.NewAndersonSketch(params)
 
 		// catch the sigterm signal for ctrl-c quitting mostly
 		// save the output at this point
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		go func() {
 			<-c
 			util.SaveOutput(csketch.Output(), outputImgName)
+			stopProfiling()
 			os.Exit(1)
 		}()
 
@@ -45,7 +81,7 @@ var andersonCmd = &cobra.Command{
 			}
 		}
 
-		util.SaveOutput(csketch.Output(), outputImgName)
+		return util.SaveOutput(csketch.Output(), outputImgName)
 	},
 }
 
@@ -56,4 +92,32 @@ func init() {
 	andersonCmd.Flags().IntVarP(&limitByIterations, "iterations", "i", 3, "Number of iterations")
 	andersonCmd.Flags().IntVarP(&width, "width", "", 1920, "Width of output")
 	andersonCmd.Flags().IntVarP(&height, "height", "", 1080, "Height of output")
+	andersonCmd.Flags().IntVarP(&andersonSlots, "slots", "", 0, "Number of colored blocks, 0 for one per color")
+	andersonCmd.Flags().Float64VarP(&andersonLeftEdge, "left-edge", "", 1, "Width of the left edge in slots")
+	andersonCmd.Flags().Float64VarP(&andersonRightEdge, "right-edge", "", 1, "Width of the right edge in slots")
+	andersonCmd.Flags().Float64VarP(&andersonHorizon, "horizon", "", 0, "Height of the horizon as a fraction from the top, 0 for random")
+	andersonCmd.Flags().StringVarP(&andersonPalette, "palette", "", "", "Block colors as hex, eg e06963,77c2a9,387db3")
+	andersonCmd.Flags().Float64VarP(&andersonWaterScale, "water-scale", "", 1.4, "Length of the reflections compared to the blocks")
+	andersonCmd.Flags().IntVarP(&andersonRippleSpacing, "ripple-spacing", "", 100, "Distance between ripples in pixels")
+	andersonCmd.Flags().BoolVarP(&andersonPainterly, "painterly", "", false, "Brush the blocks and reflect the sky in the water")
+	andersonCmd.Flags().Float64VarP(&andersonWaveAmplitude, "wave-amplitude", "", 6, "Sideways shift of the painterly reflection in pixels")
+	andersonCmd.Flags().Float64VarP(&andersonWaveLength, "wave-length", "", 40, "Distance between waves in the painterly reflection in pixels")
+	andersonCmd.Flags().IntVarP(&andersonBlur, "reflection-blur", "", 4, "Vertical blur of the painterly reflection in pixels")
+}
+
+// parsePalette reads a list of colors written as hex, eg e06963,#77c2a9
+func parsePalette(s string) ([][3]int, error) {
+	var palette [][3]int
+	if strings.TrimSpace(s) == "" {
+		return palette, nil
+	}
+	for _, hex := range strings.Split(s, ",") {
+		hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 {
+			return nil, fmt.Errorf("color %q should be six hex digits", hex)
+		}
+		palette = append(palette, [3]int{int(v >> 16), int(v >> 8 & 0xff), int(v & 0xff)})
+	}
+	return palette, nil
 }
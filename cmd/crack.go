@@ -12,31 +12,96 @@ import (
 	"gitlab.com/ericworkman/generative/util"
 )
 
+var (
+	crackLimit           = 10
+	crackSeeds           = 0
+	crackStarting        = 2
+	crackStepLength      = 0.42
+	crackAngleJitter     = 3
+	crackRegionLimit     = 0.1
+	crackSandAlpha       = 7
+	crackGrainModulation = 0.05
+	crackCurve           = "straight"
+	crackCurvature       = 1.0
+	crackNoiseScale      = 100.0
+	crackEdgesURL        = ""
+	crackEdgeThreshold   = 0.25
+	crackEdgeDensity     = 0.05
+	crackMaskURL         = ""
+	crackColorsURL       = ""
+	crackBlend           = "normal"
+)
+
 var crackCmd = &cobra.Command{
 	Use:   "crack",
 	Short: "Create sketches in the style of Jared Tarbell",
 	Long: `Create a sketch of growing cracks that "crystalize"
 `,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("crack called")
 
+		// scale the seeds to the canvas unless given
+		if crackSeeds == 0 {
+			crackSeeds = width/10 + height/10
+		}
+
 		params := sketch.CrackParams{
-			DestWidth:      width,
-			DestHeight:     height,
-			CrackLimit:     10,
-			Seeds:          width/10 + height/10,
-			StartingCracks: 2,
+			DestWidth:       width,
+			DestHeight:      height,
+			CrackLimit:      crackLimit,
+			Seeds:           crackSeeds,
+			StartingCracks:  crackStarting,
+			StepLength:      crackStepLength,
+			AngleJitter:     crackAngleJitter,
+			RegionLimit:     crackRegionLimit,
+			SandAlpha:       crackSandAlpha,
+			GrainModulation: crackGrainModulation,
+			Curve:           crackCurve,
+			Curvature:       crackCurvature,
+			NoiseScale:      crackNoiseScale,
+			EdgeThreshold:   crackEdgeThreshold,
+			EdgeDensity:     crackEdgeDensity,
+			Blend:           crackBlend,
+			HDROptions:      hdrOptions(),
+			Random:          random(),
+		}
+
+		if crackEdgesURL != "" {
+			img, err := util.LoadUnsplashImage(width, height, crackEdgesURL)
+			if err != nil {
+				return err
+			}
+			params.EdgeSource = img
+		}
+		if crackMaskURL != "" {
+			img, err := util.LoadUnsplashImage(width, height, crackMaskURL)
+			if err != nil {
+				return err
+			}
+			params.Mask = img
+		}
+		if crackColorsURL != "" {
+			img, err := util.LoadUnsplashImage(width, height, crackColorsURL)
+			if err != nil {
+				return err
+			}
+			params.ColorSource = img
+		}
+
+		if err := params.Validate(); err != nil {
+			return err
 		}
 
 		csketch := sketch.NewCrackSketch(params)
 
 		// catch the sigterm signal for ctrl-c quitting mostly
 		// save the output at this point
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		go func() {
 			<-c
 			util.SaveOutput(csketch.Output(), outputImgName)
+			stopProfiling()
 			os.Exit(1)
 		}()
 
@@ -52,7 +117,7 @@ var crackCmd = &cobra.Command{
 			}
 		}
 
-		util.SaveOutput(csketch.Output(), outputImgName)
+		return util.SaveOutput(csketch.Output(), outputImgName)
 	},
 }
 
@@ -64,4 +129,22 @@ func init() {
 	crackCmd.Flags().IntVarP(&width, "width", "", 1920, "Width of output")
 	crackCmd.Flags().IntVarP(&height, "height", "", 1080, "Height of output")
 	crackCmd.Flags().BoolVarP(&save, "save", "s", false, "Save output regularly")
+	crackCmd.Flags().IntVarP(&crackLimit, "cracks", "", 10, "Most cracks growing at once")
+	crackCmd.Flags().IntVarP(&crackSeeds, "seeds", "", 0, "Number of random crack cells to start from, 0 scales with the canvas")
+	crackCmd.Flags().IntVarP(&crackStarting, "starting-cracks", "", 2, "Number of cracks growing at the start")
+	crackCmd.Flags().Float64VarP(&crackStepLength, "step", "", 0.42, "Distance a crack grows each iteration, in pixels")
+	crackCmd.Flags().IntVarP(&crackAngleJitter, "angle-jitter", "", 3, "Most a new crack strays from perpendicular, in degrees")
+	crackCmd.Flags().Float64VarP(&crackRegionLimit, "region", "", 0.1, "Farthest sand spreads from a crack, as a fraction of the canvas")
+	crackCmd.Flags().IntVarP(&crackSandAlpha, "sand-alpha", "", 7, "Alpha of each grain of sand, 0-255")
+	crackCmd.Flags().Float64VarP(&crackGrainModulation, "grain", "", 0.05, "Most the sand grain size changes each step")
+	crackCmd.Flags().StringVarP(&crackCurve, "curve", "", "straight", "Shape of cracks: straight, constant, drift or noise")
+	crackCmd.Flags().Float64VarP(&crackCurvature, "curvature", "", 1, "Most a curved crack turns each step, in degrees")
+	crackCmd.Flags().Float64VarP(&crackNoiseScale, "noise-scale", "", 100, "Size of the noise field steering noise curves, in pixels")
+	crackCmd.Flags().StringVarP(&crackEdgesURL, "edges", "", "", "A url to an image whose edges seed the cracks")
+	crackCmd.Flags().Float64VarP(&crackEdgeThreshold, "edge-threshold", "", 0.25, "Smallest edge strength that seeds a crack, 0-1")
+	crackCmd.Flags().Float64VarP(&crackEdgeDensity, "edge-density", "", 0.05, "Chance each edge pixel starts a line of seeds along its edge, 0-1")
+	crackCmd.Flags().StringVarP(&crackMaskURL, "mask", "", "", "A url to an image, cracks only grow in its bright parts")
+	crackCmd.Flags().StringVarP(&crackColorsURL, "colors", "", "", "A url to an image to color the sand from, instead of the desert palette")
+	crackCmd.Flags().StringVarP(&crackBlend, "blend", "", "normal", "How sand combines with the canvas: normal, add, screen, multiply or lighten")
+	addHDRFlags(crackCmd, "sand")
 }
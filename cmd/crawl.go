@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/spf13/cobra"
@@ -12,8 +14,17 @@ import (
 )
 
 var (
-	crawlCount = 3
-	crawlStart = "center"
+	crawlCount        = 3
+	crawlStart        = "center"
+	crawlCollision    = "none"
+	crawlBranchChance = 0.0
+	crawlMax          = 100
+	crawlPoints       = ""
+	crawlEdge         = "stop"
+	crawlField        = "none"
+	crawlFieldScale   = 200.0
+	crawlFieldForce   = 0.8
+	crawlBlend        = "normal"
 )
 
 // crawlCmd represents the crawl command
@@ -21,25 +32,50 @@ var crawlCmd = &cobra.Command{
 	Use:   "crawl",
 	Short: "Create crawling lines from a center point",
 	Long:  ``,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("crawl called")
 		params := sketch.CrawlParams{
-			DestWidth:  width,
-			DestHeight: height,
-			Iterations: limitByIterations,
-			Count:      crawlCount,
-			Start:      crawlStart,
+			DestWidth:     width,
+			DestHeight:    height,
+			Iterations:    limitByIterations,
+			Count:         crawlCount,
+			Start:         crawlStart,
+			Collision:     crawlCollision,
+			BranchChance:  crawlBranchChance,
+			MaxCrawlers:   crawlMax,
+			Edge:          crawlEdge,
+			Field:         crawlField,
+			FieldScale:    crawlFieldScale,
+			FieldStrength: crawlFieldForce,
+			Blend:         crawlBlend,
+			Random:        random(),
+		}
+		if crawlField == "image" {
+			img, err := util.LoadUnsplashImage(width, height, url)
+			if err != nil {
+				return err
+			}
+			params.FieldSource = img
+		}
+		points, err := parsePoints(crawlPoints)
+		if err != nil {
+			return err
+		}
+		params.Points = points
+		if err := params.Validate(); err != nil {
+			return err
 		}
 
 		csketch := sketch.NewCrawlSketch(params)
 
 		// catch the sigterm signal for ctrl-c quitting mostly
 		// save the output at this point
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		go func() {
 			<-c
 			util.SaveOutput(csketch.Output(), outputImgName)
+			stopProfiling()
 			os.Exit(1)
 		}()
 
@@ -54,7 +90,7 @@ var crawlCmd = &cobra.Command{
 			}
 		}
 
-		util.SaveOutput(csketch.Output(), outputImgName)
+		return util.SaveOutput(csketch.Output(), outputImgName)
 	},
 }
 
@@ -65,5 +101,39 @@ func init() {
 	crawlCmd.Flags().IntVarP(&width, "width", "", 1920, "Width of output")
 	crawlCmd.Flags().IntVarP(&height, "height", "", 1080, "Height of output")
 	crawlCmd.Flags().IntVarP(&crawlCount, "count", "", 3, "Number of crawlers")
-	crawlCmd.Flags().StringVarP(&crawlStart, "start", "", "center", "center or corner starting location")
+	crawlCmd.Flags().StringVarP(&crawlStart, "start", "", "center", "Starting location: center, corner, random, edge, ring or points")
+	crawlCmd.Flags().StringVarP(&crawlPoints, "points", "", "", "Starting points for --start points, as x,y;x,y")
+	crawlCmd.Flags().StringVarP(&crawlEdge, "edge", "", "stop", "What crawlers do at the edge: stop, wrap or reflect")
+	crawlCmd.Flags().StringVarP(&crawlField, "field", "", "none", "Flow field steering the crawlers: none, noise, curl or image")
+	crawlCmd.Flags().Float64VarP(&crawlFieldScale, "field-scale", "", 200, "Size of features in the noise and curl fields, in pixels")
+	crawlCmd.Flags().Float64VarP(&crawlFieldForce, "field-strength", "", 0.8, "How closely crawlers follow the field, 0-1")
+	crawlCmd.Flags().StringVarP(&url, "url", "u", "", "A url to an image for the image field")
+	crawlCmd.Flags().StringVarP(&crawlCollision, "collision", "", "none", "What crawlers do at another trail: none, avoid or stop")
+	crawlCmd.Flags().Float64VarP(&crawlBranchChance, "branch", "", 0, "Chance each step that a crawler branches, 0-1")
+	crawlCmd.Flags().StringVarP(&crawlBlend, "blend", "", "normal", "How background lines combine: normal, add, screen, multiply or lighten")
+	crawlCmd.Flags().IntVarP(&crawlMax, "max-crawlers", "", 100, "Most crawlers once they branch")
+}
+
+// parsePoints reads a list of points written as x,y;x,y
+func parsePoints(s string) ([][2]float64, error) {
+	var points [][2]float64
+	if strings.TrimSpace(s) == "" {
+		return points, nil
+	}
+	for _, pair := range strings.Split(s, ";") {
+		xy := strings.Split(pair, ",")
+		if len(xy) != 2 {
+			return nil, fmt.Errorf("point %q should be x,y", pair)
+		}
+		x, err := strconv.ParseFloat(strings.TrimSpace(xy[0]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("point %q: %v", pair, err)
+		}
+		y, err := strconv.ParseFloat(strings.TrimSpace(xy[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("point %q: %v", pair, err)
+		}
+		points = append(points, [2]float64{x, y})
+	}
+	return points, nil
 }
@@ -11,28 +11,58 @@ import (
 	"gitlab.com/ericworkman/generative/util"
 )
 
+var (
+	fireworkShells     = 8
+	fireworkParticles  = 150
+	fireworkBurstSpeed = 0.0
+	fireworkGravity    = 0.0
+	fireworkDrag       = 0.05
+	fireworkLife       = 60
+	fireworkBlend      = "add"
+)
+
 // fireworkCmd represents the firework command
 var fireworkCmd = &cobra.Command{
 	Use:   "firework",
 	Short: "Generate a firework",
 	Long:  ``,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("firework called")
+		// speeds default to a fraction of the height so bursts look the same at any size
+		if fireworkBurstSpeed == 0 {
+			fireworkBurstSpeed = float64(height) / 80
+		}
+		if fireworkGravity == 0 {
+			fireworkGravity = float64(height) / 2000
+		}
 		params := sketch.FireworkParams{
 			DestWidth:  width,
 			DestHeight: height,
 			Iterations: limitByIterations,
+			Shells:     fireworkShells,
+			Particles:  fireworkParticles,
+			BurstSpeed: fireworkBurstSpeed,
+			Gravity:    fireworkGravity,
+			Drag:       fireworkDrag,
+			Life:       fireworkLife,
+			Blend:      fireworkBlend,
+			HDROptions: hdrOptions(),
+			Random:     random(),
+		}
+		if err := params.Validate(); err != nil {
+			return err
 		}
 
 		csketch := sketch.NewFireworkSketch(params)
 
 		// catch the sigterm signal for ctrl-c quitting mostly
 		// save the output at this point
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		go func() {
 			<-c
 			util.SaveOutput(csketch.Output(), outputImgName)
+			stopProfiling()
 			os.Exit(1)
 		}()
 
@@ -47,14 +77,22 @@ var fireworkCmd = &cobra.Command{
 			}
 		}
 
-		util.SaveOutput(csketch.Output(), outputImgName)
+		return util.SaveOutput(csketch.Output(), outputImgName)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(fireworkCmd)
 	fireworkCmd.Flags().StringVarP(&outputImgName, "out", "o", "out.png", "Output image name")
-	fireworkCmd.Flags().IntVarP(&limitByIterations, "iterations", "i", 3, "Number of iterations")
+	fireworkCmd.Flags().IntVarP(&limitByIterations, "iterations", "i", 100, "Number of iterations")
 	fireworkCmd.Flags().IntVarP(&width, "width", "", 1920, "Width of output")
 	fireworkCmd.Flags().IntVarP(&height, "height", "", 1080, "Height of output")
+	fireworkCmd.Flags().IntVarP(&fireworkShells, "shells", "", 8, "Number of shells launched")
+	fireworkCmd.Flags().IntVarP(&fireworkParticles, "particles", "", 150, "Number of particles in each burst")
+	fireworkCmd.Flags().Float64VarP(&fireworkBurstSpeed, "burst-speed", "", 0, "Fastest particle speed in pixels per iteration, 0 for height/80")
+	fireworkCmd.Flags().Float64VarP(&fireworkGravity, "gravity", "", 0, "Pull downwards in pixels per iteration squared, 0 for height/2000")
+	fireworkCmd.Flags().Float64VarP(&fireworkDrag, "drag", "", 0.05, "Fraction of speed particles lose each iteration")
+	fireworkCmd.Flags().IntVarP(&fireworkLife, "life", "", 60, "Iterations particles glow for")
+	fireworkCmd.Flags().StringVarP(&fireworkBlend, "blend", "", "add", "How sparks combine with the sky: normal, add, screen, multiply or lighten")
+	addHDRFlags(fireworkCmd, "sparks")
 }
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -13,41 +14,74 @@ import (
 )
 
 var (
-	divisions = 12
+	divisions            = 12
+	flipTiling           = "diamond"
+	flipMirrorChance     = 0.0
+	flipRotateChance     = 0.25
+	flipRotateQuarter    = false
+	flipScaleChance      = 0.0
+	flipScale            = 1.5
+	flipHueChance        = 0.0
+	flipHueShift         = 180.0
+	flipDesaturateChance = 0.0
+	flipBorderChance     = 0.03
+	flipMargin           = 1.0
 )
 
 // flipCmd represents the stack command
 var flipCmd = &cobra.Command{
 	Use:   "flip",
-	Short: "Flip and style an image using diamonds",
+	Short: "Flip and style an image using diamonds, squares, hexagons, triangles, penrose rhombi or voronoi cells",
 	Long:  `Single pass only`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("flip called")
-		img, _ := util.LoadUnsplashImage(width, height, url)
-
+		source, err := sourceOptions()
+		if err != nil {
+			return err
+		}
 		params := sketch.FlipParams{
-			DestWidth:  width,
-			DestHeight: height,
+			DestWidth:        width,
+			DestHeight:       height,
+			Tiling:           flipTiling,
+			MirrorChance:     flipMirrorChance,
+			RotateChance:     flipRotateChance,
+			RotateQuarter:    flipRotateQuarter,
+			ScaleChance:      flipScaleChance,
+			Scale:            flipScale,
+			HueChance:        flipHueChance,
+			HueShift:         flipHueShift,
+			DesaturateChance: flipDesaturateChance,
+			BorderChance:     flipBorderChance,
+			Margin:           flipMargin,
+			SourceOptions:    source,
+			Random:           random(),
+		}
+		if err := params.Validate(); err != nil {
+			return err
+		}
+		img, err := util.LoadUnsplashImage(width, height, url)
+		if err != nil {
+			return err
 		}
 
 		csketch := sketch.NewFlipSketch(img, params)
 
 		// catch the sigterm signal for ctrl-c quitting mostly
 		// save the output at this point
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		go func() {
 			<-c
 			util.SaveOutput(csketch.Output(), outputImgName)
+			stopProfiling()
 			os.Exit(1)
 		}()
 
-		csketch.Draw(divisions)
-		if save == true {
-			util.SaveOutput(csketch.Output(), outputImgName)
+		if err := csketch.Draw(context.Background(), divisions); err != nil {
+			return err
 		}
 
-		util.SaveOutput(csketch.Output(), outputImgName)
+		return util.SaveOutput(csketch.Output(), outputImgName)
 	},
 }
 
@@ -60,4 +94,16 @@ func init() {
 	flipCmd.Flags().IntVarP(&height, "height", "", 1080, "Height of output")
 	flipCmd.Flags().BoolVarP(&save, "save", "s", false, "Save output regularly")
 	flipCmd.Flags().IntVarP(&divisions, "divisions", "d", 12, "Divisions of height")
+	flipCmd.Flags().StringVarP(&flipTiling, "tiling", "", "diamond", "Tile shape: diamond, square, hex, triangle, penrose or voronoi")
+	flipCmd.Flags().Float64VarP(&flipMirrorChance, "mirror", "", 0, "Chance a tile is mirrored")
+	flipCmd.Flags().Float64VarP(&flipRotateChance, "rotate", "", 0.25, "Chance a tile is turned 180 degrees")
+	flipCmd.Flags().BoolVarP(&flipRotateQuarter, "rotate-quarter", "", false, "Turn rotated tiles by a random multiple of 90 degrees instead")
+	flipCmd.Flags().Float64VarP(&flipScaleChance, "scale-chance", "", 0, "Chance a tile is zoomed")
+	flipCmd.Flags().Float64VarP(&flipScale, "scale", "", 1.5, "Zoom of scaled tiles")
+	flipCmd.Flags().Float64VarP(&flipHueChance, "hue-chance", "", 0, "Chance a tile's hue is shifted")
+	flipCmd.Flags().Float64VarP(&flipHueShift, "hue-shift", "", 180, "Degrees the hue of shifted tiles turns")
+	flipCmd.Flags().Float64VarP(&flipDesaturateChance, "desaturate", "", 0, "Chance a tile is drawn in gray")
+	flipCmd.Flags().Float64VarP(&flipBorderChance, "border", "", 0.03, "Chance a tile gets a white border")
+	flipCmd.Flags().Float64VarP(&flipMargin, "margin", "", 1, "Tiles within this many tile sizes of the edge are left alone, the original layout kept about 2 to 4 along each edge")
+	addSourceFlags(flipCmd)
 }
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -11,6 +12,14 @@ import (
 var (
 	vignette = false
 	size     = 20.0
+
+	gridShape    = "circle"
+	gridSides    = 5
+	gridHalftone = false
+	gridLattice  = "square"
+	gridJitter   = 0.3
+	gridVignette = "none"
+	gridRotation = 0.0
 )
 
 // gridCmd represents the grid command
@@ -18,22 +27,42 @@ var gridCmd = &cobra.Command{
 	Use:   "grid",
 	Short: "Create a grided image",
 	Long:  ``,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("grid called")
 
-		img, _ := util.LoadUnsplashImage(width, height, url)
+		source, err := sourceOptions()
+		if err != nil {
+			return err
+		}
+		img, err := util.LoadUnsplashImage(width, height, url)
+		if err != nil {
+			return err
+		}
 
 		params := sketch.GridParams{
-			DestWidth:  width,
-			DestHeight: height,
-			Vignette:   vignette,
-			Size:       size,
+			DestWidth:     width,
+			DestHeight:    height,
+			SourceOptions: source,
+			Size:          size,
+			Shape:         gridShape,
+			Sides:         gridSides,
+			Halftone:      gridHalftone,
+			Lattice:       gridLattice,
+			Jitter:        gridJitter,
+			Vignette:      gridVignette,
+			Rotation:      gridRotation,
+			Random:        random(),
+		}
+		if err := params.Validate(); err != nil {
+			return err
 		}
 
 		csketch := sketch.NewGridSketch(img, params)
-		csketch.Draw()
+		if err := csketch.Draw(context.Background()); err != nil {
+			return err
+		}
 
-		util.SaveOutput(csketch.Output(), outputImgName)
+		return util.SaveOutput(csketch.Output(), outputImgName)
 	},
 }
 
@@ -44,5 +73,12 @@ func init() {
 	gridCmd.Flags().IntVarP(&width, "width", "", 1920, "Width of output")
 	gridCmd.Flags().IntVarP(&height, "height", "", 1080, "Height of output")
 	gridCmd.Flags().Float64VarP(&size, "size", "s", 20.0, "Size of grid")
-	gridCmd.Flags().BoolVarP(&vignette, "vignette", "", false, "Vignette on the x-axis")
+	gridCmd.Flags().StringVarP(&gridVignette, "vignette", "", "none", "How the grid fades towards the edges: none, x, radial or elliptical")
+	gridCmd.Flags().StringVarP(&gridShape, "shape", "", "circle", "Mark at each point: circle, square, hexagon, polygon or line")
+	gridCmd.Flags().IntVarP(&gridSides, "sides", "", 5, "Number of sides of polygon marks")
+	gridCmd.Flags().BoolVarP(&gridHalftone, "halftone", "", false, "Size marks by the brightness of the image")
+	gridCmd.Flags().StringVarP(&gridLattice, "lattice", "", "square", "Layout of points: square, hex or jitter")
+	gridCmd.Flags().Float64VarP(&gridJitter, "jitter", "", 0.3, "How far jittered points move, as a fraction of size")
+	gridCmd.Flags().Float64VarP(&gridRotation, "rotation", "", 0, "Degrees the grid and marks are turned")
+	addSourceFlags(gridCmd)
 }
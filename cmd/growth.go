@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -8,23 +9,40 @@ import (
 	"gitlab.com/ericworkman/generative/util"
 )
 
+var (
+	growthMetric        = "chebyshev"
+	growthSpeedVariance = 0.0
+	growthMaxDelay      = 0.0
+	growthAnisotropy    = 0.0
+)
+
 var growthCmd = &cobra.Command{
 	Use:   "growth",
 	Short: "Grow crystals from seeds",
 	Long:  ``,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("growth called")
 		params := sketch.GrowthParams{
 			DestWidth:     width,
 			DestHeight:    height,
 			StartingSeeds: seeds,
+			Metric:        growthMetric,
+			SpeedVariance: growthSpeedVariance,
+			MaxDelay:      growthMaxDelay,
+			Anisotropy:    growthAnisotropy,
+			Random:        random(),
+		}
+		if err := params.Validate(); err != nil {
+			return err
 		}
 
 		ssketch := sketch.NewGrowthSketch(params)
 
-		ssketch.Draw()
+		if err := ssketch.Draw(context.Background()); err != nil {
+			return err
+		}
 
-		util.SaveOutput(ssketch.Output(), outputImgName)
+		return util.SaveOutput(ssketch.Output(), outputImgName)
 	},
 }
 
@@ -34,4 +52,8 @@ func init() {
 	growthCmd.Flags().IntVarP(&width, "width", "", 1920, "Width of output")
 	growthCmd.Flags().IntVarP(&height, "height", "", 1080, "Height of output")
 	growthCmd.Flags().IntVarP(&seeds, "seeds", "", 5, "Number of starting seeds")
+	growthCmd.Flags().StringVarP(&growthMetric, "metric", "", "chebyshev", "Shape of growth: chebyshev (squares), manhattan (diamonds) or euclidean (circles)")
+	growthCmd.Flags().Float64VarP(&growthSpeedVariance, "speed-variance", "", 0, "Vary each seed's speed by up to this fraction, between 0 and 1")
+	growthCmd.Flags().Float64VarP(&growthMaxDelay, "max-delay", "", 0, "Delay each seed's start by up to this many steps")
+	growthCmd.Flags().Float64VarP(&growthAnisotropy, "anisotropy", "", 0, "Stretch each seed's growth by up to 1+anisotropy in a random direction")
 }
@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"gitlab.com/ericworkman/generative/sketch"
+)
+
+// addHDRFlags adds the flags that switch a command to a float canvas and develop it, strokes names what accumulates
+func addHDRFlags(c *cobra.Command, strokes string) {
+	c.Flags().BoolVarP(&hdr, "hdr", "", false, "Accumulate "+strokes+" on a float canvas and develop it with --exposure and --gamma")
+	c.Flags().Float64VarP(&exposure, "exposure", "", 0, "Brightness of the hdr canvas in stops")
+	c.Flags().Float64VarP(&gamma, "gamma", "", 1, "Gamma of the hdr canvas, above 1 lifts the shadows")
+}
+
+// hdrOptions collects the hdr flags into options for a sketch
+func hdrOptions() sketch.HDROptions {
+	return sketch.HDROptions{HDR: hdr, Exposure: exposure, Gamma: gamma}
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"errors"
+
 	"github.com/spf13/cobra"
 
 	"gitlab.com/ericworkman/generative/sketch"
@@ -23,9 +25,16 @@ var layerCmd = &cobra.Command{
 	Short: "Create sketches in the style of Preslav Rachev",
 	Long: `Create a sketch of overlapping shapes with various drawing options
 `,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 
-		img, _ := util.LoadUnsplashImage(width, height, url)
+		source, err := sourceOptions()
+		if err != nil {
+			return err
+		}
+		img, err := util.LoadUnsplashImage(width, height, url)
+		if err != nil {
+			return err
+		}
 
 		if edgeMin > edgeMax {
 			edgeMax = edgeMin
@@ -34,6 +43,8 @@ var layerCmd = &cobra.Command{
 		params := sketch.LayerParams{
 			DestWidth:              width,
 			DestHeight:             height,
+			SourceOptions:          source,
+			HDROptions:             hdrOptions(),
 			PathRatio:              ratio,
 			PathReduction:          reduction,
 			PathMin:                limitBySize,
@@ -44,6 +55,13 @@ var layerCmd = &cobra.Command{
 			MaxEdgeCount:           edgeMax,
 			Edge:                   edge,
 			PathInversionThreshold: inversionThreshold,
+			Random:                 random(),
+		}
+		if err := params.Validate(); err != nil {
+			return err
+		}
+		if reduction <= 0 && limitByIterations == 0 {
+			return errors.New("reduction must be positive when iterations is not set")
 		}
 
 		lsketch := sketch.NewLayerSketch(img, params)
@@ -58,7 +76,7 @@ var layerCmd = &cobra.Command{
 			}
 		}
 
-		util.SaveOutput(lsketch.Output(), outputImgName)
+		return util.SaveOutput(lsketch.Output(), outputImgName)
 	},
 }
 
@@ -81,4 +99,6 @@ func init() {
 	layerCmd.Flags().Float64VarP(&jitter, "jitter", "", 0.007, "Jitter multiplier")
 	layerCmd.Flags().BoolVarP(&edge, "edge", "", false, "Paint edges with inversion")
 	layerCmd.Flags().Float64VarP(&inversionThreshold, "inversion", "", 0.05, "Size at which to invert the color")
+	addSourceFlags(layerCmd)
+	addHDRFlags(layerCmd, "shapes")
 }
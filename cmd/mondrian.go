@@ -17,24 +17,34 @@ var mondrianCmd = &cobra.Command{
 	Use:   "mondrian",
 	Short: "Create rectangles with border from a sample image",
 	Long:  `Use iterations < 150 for regular usage`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("mondrian called")
-		img, _ := util.LoadUnsplashImage(width, height, url)
+		source, err := sourceOptions()
+		if err != nil {
+			return err
+		}
+		img, err := util.LoadUnsplashImage(width, height, url)
+		if err != nil {
+			return err
+		}
 
 		params := sketch.MondrianParams{
-			DestWidth:  width,
-			DestHeight: height,
+			DestWidth:     width,
+			DestHeight:    height,
+			SourceOptions: source,
+			Random:        random(),
 		}
 
 		csketch := sketch.NewMondrianSketch(img, params)
 
 		// catch the sigterm signal for ctrl-c quitting mostly
 		// save the output at this point
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		go func() {
 			<-c
 			util.SaveOutput(csketch.Output(), outputImgName)
+			stopProfiling()
 			os.Exit(1)
 		}()
 
@@ -47,7 +57,7 @@ var mondrianCmd = &cobra.Command{
 			}
 		}
 
-		util.SaveOutput(csketch.Output(), outputImgName)
+		return util.SaveOutput(csketch.Output(), outputImgName)
 	},
 }
 
@@ -60,4 +70,5 @@ func init() {
 	mondrianCmd.Flags().IntVarP(&width, "width", "", 1920, "Width of output")
 	mondrianCmd.Flags().IntVarP(&height, "height", "", 1080, "Height of output")
 	mondrianCmd.Flags().BoolVarP(&save, "save", "s", false, "Save output regularly")
+	addSourceFlags(mondrianCmd)
 }
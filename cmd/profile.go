@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+)
+
+var (
+	cpuProfile = ""
+	memProfile = ""
+	traceFile  = ""
+
+	cpuProfileFile *os.File
+	traceOutFile   *os.File
+	stopOnce       sync.Once
+)
+
+// startProfiling begins any profiles requested on the command line
+func startProfiling() error {
+	if cpuProfile != "" {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		cpuProfileFile = f
+	}
+
+	if traceFile != "" {
+		f, err := os.Create(traceFile)
+		if err != nil {
+			return err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			return err
+		}
+		traceOutFile = f
+	}
+
+	return nil
+}
+
+// stopProfiling flushes the profiles started by startProfiling and writes the heap profile
+// It runs once however the command ends, so a ctrl-c handler and Execute can both call it.
+func stopProfiling() {
+	stopOnce.Do(flushProfiles)
+}
+
+func flushProfiles() {
+	if cpuProfileFile != nil {
+		pprof.StopCPUProfile()
+		cpuProfileFile.Close()
+		cpuProfileFile = nil
+	}
+
+	if traceOutFile != nil {
+		trace.Stop()
+		traceOutFile.Close()
+		traceOutFile = nil
+	}
+
+	if memProfile != "" {
+		f, err := os.Create(memProfile)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer f.Close()
+		// collect garbage first so the profile shows live memory
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"gitlab.com/ericworkman/generative/sketch"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
@@ -31,9 +32,20 @@ var (
 
 	spiralBeta = 1.0
 	spiralMu   = 0.1
+
+	fit    = "cover"
+	anchor = "center"
+	crop   []float64
+
+	hdr      = false
+	exposure = 0.0
+	gamma    = 1.0
 )
 
-var cfgFile string
+var (
+	cfgFile string
+	seed    int64
+)
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -44,12 +56,28 @@ var rootCmd = &cobra.Command{
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	//	Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// a fixed seed makes a sketch repeatable, otherwise pick one from the clock
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		rand.Seed(seed)
+		return startProfiling()
+	},
+}
+
+// random gives a sketch its own source from the seed, so --seed draws the same sketch as a seeded render from the server
+func random() sketch.Random {
+	return sketch.Random{Rand: rand.New(rand.NewSource(seed))}
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// profiles are flushed here rather than in a post run hook, which cobra skips when a command fails
+	err := rootCmd.Execute()
+	stopProfiling()
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -64,6 +92,10 @@ func init() {
 	// will be global for your application.
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.generative.yaml)")
+	rootCmd.PersistentFlags().Int64VarP(&seed, "seed", "", 0, "Random seed for repeatable output, 0 picks one from the clock")
+	rootCmd.PersistentFlags().StringVarP(&cpuProfile, "cpuprofile", "", "", "Write a cpu profile to this file")
+	rootCmd.PersistentFlags().StringVarP(&memProfile, "memprofile", "", "", "Write a heap profile to this file when done")
+	rootCmd.PersistentFlags().StringVarP(&traceFile, "trace", "", "", "Write an execution trace to this file")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -15,22 +16,32 @@ var rowsCmd = &cobra.Command{
 	Use:   "rows",
 	Short: "Create a row-based image",
 	Long:  ``,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("rows called")
 
-		img, _ := util.LoadUnsplashImage(width, height, url)
+		source, err := sourceOptions()
+		if err != nil {
+			return err
+		}
+		img, err := util.LoadUnsplashImage(width, height, url)
+		if err != nil {
+			return err
+		}
 
 		params := sketch.RowsParams{
-			DestWidth:  width,
-			DestHeight: height,
-			Vignette:   vignette,
-			Size:       size,
+			DestWidth:     width,
+			DestHeight:    height,
+			SourceOptions: source,
+			Vignette:      vignette,
+			Size:          size,
 		}
 
 		csketch := sketch.NewRowsSketch(img, params)
-		csketch.Draw()
+		if err := csketch.Draw(context.Background()); err != nil {
+			return err
+		}
 
-		util.SaveOutput(csketch.Output(), outputImgName)
+		return util.SaveOutput(csketch.Output(), outputImgName)
 	},
 }
 
@@ -42,4 +53,5 @@ func init() {
 	rowsCmd.Flags().IntVarP(&height, "height", "", 1080, "Height of output")
 	rowsCmd.Flags().Float64VarP(&size, "size", "s", 20.0, "Size of grid")
 	rowsCmd.Flags().BoolVarP(&vignette, "vignette", "", false, "Vignette on the x-axis")
+	addSourceFlags(rowsCmd)
 }
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gitlab.com/ericworkman/generative/server"
+)
+
+var (
+	serveAddr      = ":8080"
+	serveWorkers   = runtime.NumCPU()
+	serveQueue     = 64
+	serveTimeout   = 2 * time.Minute
+	serveCache     = 128
+	serveMaxWidth  = 4096
+	serveMaxHeight = 4096
+	serveMaxIter   = 100000
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Render sketches on demand over http",
+	Long: `Serve POST /render/{sketch} with a json body and respond with a png.
+
+The body takes width, height, seed, iterations, url (for sketches using a source image),
+palette as a list of [r, g, b], and params for the sketch specific fields, eg
+
+  curl -d '{"width": 800, "height": 600, "seed": 7, "params": {"crackLimit": 20}}' localhost:8080/render/crack > crack.png
+
+GET /sketches lists the available sketches.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Println("serve called")
+		srv := server.New(server.Config{
+			Workers:       serveWorkers,
+			QueueSize:     serveQueue,
+			Timeout:       serveTimeout,
+			CacheSize:     serveCache,
+			MaxWidth:      serveMaxWidth,
+			MaxHeight:     serveMaxHeight,
+			MaxIterations: serveMaxIter,
+		})
+		srv.Start()
+
+		// the server only stops on ctrl-c, so flush any profiles then
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-c
+			stopProfiling()
+			os.Exit(1)
+		}()
+
+		fmt.Println("Listening on", serveAddr)
+		return http.ListenAndServe(serveAddr, srv)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVarP(&serveAddr, "addr", "", ":8080", "Address to listen on")
+	serveCmd.Flags().IntVarP(&serveWorkers, "workers", "", runtime.NumCPU(), "Number of sketches rendered at once")
+	serveCmd.Flags().IntVarP(&serveQueue, "queue", "", 64, "Number of requests waiting for a worker before new ones are turned away")
+	serveCmd.Flags().DurationVarP(&serveTimeout, "timeout", "", 2*time.Minute, "Time limit for each request")
+	serveCmd.Flags().IntVarP(&serveCache, "cache", "", 128, "Number of seeded renders to keep in memory")
+	serveCmd.Flags().IntVarP(&serveMaxWidth, "max-width", "", 4096, "Largest width accepted")
+	serveCmd.Flags().IntVarP(&serveMaxHeight, "max-height", "", 4096, "Largest height accepted")
+	serveCmd.Flags().IntVarP(&serveMaxIter, "max-iterations", "", 100000, "Most iterations accepted")
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+	"gitlab.com/ericworkman/generative/sketch"
+)
+
+// addSourceFlags adds the flags that control how a command's source image is fitted to the output
+func addSourceFlags(c *cobra.Command) {
+	c.Flags().StringVarP(&fit, "fit", "", "cover", "How the image is fitted to the output: cover, contain, stretch or none")
+	c.Flags().StringVarP(&anchor, "anchor", "", "center", "Part of the image kept or lined up when it doesn't fit: center, top, bottom, left, right, top-left, top-right, bottom-left or bottom-right")
+	c.Flags().Float64SliceVarP(&crop, "crop", "", nil, "Fractions trimmed from the left, top, right and bottom of the image, like 0.1,0,0.1,0")
+}
+
+// sourceOptions collects the source flags into options for a sketch
+func sourceOptions() (sketch.SourceOptions, error) {
+	o := sketch.SourceOptions{Fit: fit, Anchor: anchor}
+	if len(crop) > 0 {
+		if len(crop) != 4 {
+			return o, errors.New("crop needs four fractions: left, top, right and bottom")
+		}
+		copy(o.Crop[:], crop)
+	}
+	return o, o.Validate()
+}
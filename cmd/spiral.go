@@ -11,12 +11,22 @@ import (
 	"gitlab.com/ericworkman/generative/util"
 )
 
+var (
+	spiralFamily    = "log"
+	spiralArms      = 1
+	spiralStep      = 0.0
+	spiralCenterX   = 0.5
+	spiralCenterY   = 0.5
+	spiralColorMode = "random"
+	spiralSpace     = "lab"
+)
+
 // spiralCmd represents the spiral command
 var spiralCmd = &cobra.Command{
 	Use:   "spiral",
-	Short: "Create a logarithmic spiral",
+	Short: "Create a logarithmic, archimedean, fermat or hyperbolic spiral",
 	Long:  ``,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("spiral called")
 		params := sketch.SpiralParams{
 			DestWidth:  width,
@@ -24,17 +34,36 @@ var spiralCmd = &cobra.Command{
 			Iterations: limitByIterations,
 			Beta:       spiralBeta,
 			Mu:         spiralMu,
+			Family:     spiralFamily,
+			Arms:       spiralArms,
+			Step:       spiralStep,
+			CenterX:    spiralCenterX,
+			CenterY:    spiralCenterY,
+			ColorMode:  spiralColorMode,
+			ColorSpace: spiralSpace,
+			Random:     random(),
+		}
+		if spiralColorMode == "image" {
+			img, err := util.LoadUnsplashImage(width, height, url)
+			if err != nil {
+				return err
+			}
+			params.ColorSource = img
+		}
+		if err := params.Validate(); err != nil {
+			return err
 		}
 
 		csketch := sketch.NewSpiralSketch(params)
 
 		// catch the sigterm signal for ctrl-c quitting mostly
 		// save the output at this point
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		go func() {
 			<-c
 			util.SaveOutput(csketch.Output(), outputImgName)
+			stopProfiling()
 			os.Exit(1)
 		}()
 
@@ -47,7 +76,7 @@ var spiralCmd = &cobra.Command{
 			}
 		}
 
-		util.SaveOutput(csketch.Output(), outputImgName)
+		return util.SaveOutput(csketch.Output(), outputImgName)
 	},
 }
 
@@ -60,4 +89,12 @@ func init() {
 	spiralCmd.Flags().IntVarP(&height, "height", "", 1080, "Height of output")
 	spiralCmd.Flags().Float64VarP(&spiralBeta, "beta", "", 1, "Tweakable scale of spiral")
 	spiralCmd.Flags().Float64VarP(&spiralMu, "mu", "", 0.100, "Tweakable speed of growth of spiral")
+	spiralCmd.Flags().StringVarP(&spiralFamily, "family", "", "log", "Kind of spiral: log, archimedean, fermat or hyperbolic")
+	spiralCmd.Flags().IntVarP(&spiralArms, "arms", "", 1, "Number of arms turned evenly around the center")
+	spiralCmd.Flags().Float64VarP(&spiralStep, "step", "", 0, "Angle between dots in radians, 0 for the family's default")
+	spiralCmd.Flags().Float64VarP(&spiralCenterX, "center-x", "", 0.5, "Horizontal center as a fraction of the width")
+	spiralCmd.Flags().Float64VarP(&spiralCenterY, "center-y", "", 0.5, "Vertical center as a fraction of the height")
+	spiralCmd.Flags().StringVarP(&spiralColorMode, "colors", "", "random", "Dot colors: random, curve, radius, angle or image")
+	spiralCmd.Flags().StringVarP(&spiralSpace, "color-space", "", "lab", "Space gradient colors are mixed in: hsl or lab")
+	spiralCmd.Flags().StringVarP(&url, "url", "u", "", "A url to an image for the image colors")
 }
@@ -17,24 +17,33 @@ var stackCmd = &cobra.Command{
 	Use:   "stack",
 	Short: "Create an image of a stack of transparent shapes on a finer and finer grid",
 	Long:  `Use iterations < 150 for regular usage`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("stack called")
-		img, _ := util.LoadUnsplashImage(width, height, url)
+		source, err := sourceOptions()
+		if err != nil {
+			return err
+		}
+		img, err := util.LoadUnsplashImage(width, height, url)
+		if err != nil {
+			return err
+		}
 
 		params := sketch.StackParams{
-			DestWidth:  width,
-			DestHeight: height,
+			DestWidth:     width,
+			DestHeight:    height,
+			SourceOptions: source,
 		}
 
 		csketch := sketch.NewStackSketch(img, params)
 
 		// catch the sigterm signal for ctrl-c quitting mostly
 		// save the output at this point
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		go func() {
 			<-c
 			util.SaveOutput(csketch.Output(), outputImgName)
+			stopProfiling()
 			os.Exit(1)
 		}()
 
@@ -47,7 +56,7 @@ var stackCmd = &cobra.Command{
 			}
 		}
 
-		util.SaveOutput(csketch.Output(), outputImgName)
+		return util.SaveOutput(csketch.Output(), outputImgName)
 	},
 }
 
@@ -60,4 +69,5 @@ func init() {
 	stackCmd.Flags().IntVarP(&width, "width", "", 1920, "Width of output")
 	stackCmd.Flags().IntVarP(&height, "height", "", 1080, "Height of output")
 	stackCmd.Flags().BoolVarP(&save, "save", "s", false, "Save output regularly")
+	addSourceFlags(stackCmd)
 }
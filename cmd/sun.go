@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -19,11 +20,13 @@ var sunCmd = &cobra.Command{
 			DestHeight: height,
 			SunRadius:  beta,
 			LineWidth:  mu,
+			Random:     random(),
 		}
 
 		ssketch := sketch.NewSunSketch(params)
 
-		ssketch.Draw()
+		// the cli never cancels the drawing
+		ssketch.Draw(context.Background())
 
 		util.SaveOutput(ssketch.Output(), outputImgName)
 	},
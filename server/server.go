@@ -0,0 +1,296 @@
+package server
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/ericworkman/generative/sketch"
+	"gitlab.com/ericworkman/generative/util"
+)
+
+// Config contains the limits of the render service
+type Config struct {
+	Workers   int
+	QueueSize int
+	Timeout   time.Duration
+	CacheSize int
+	MaxWidth  int
+	MaxHeight int
+	// MaxIterations is the most iterations a request can ask for
+	MaxIterations int
+}
+
+// Server renders sketches over http
+// Requests are queued and picked up by a fixed number of workers, so no more than Workers sketches draw at once.
+type Server struct {
+	Config
+	jobs  chan *job
+	cache *cache
+}
+
+// maxBodySize is the largest render request read, far more than any sketch's params need
+const maxBodySize = 1 << 20
+
+// Params is the json body of a render request
+// Params holds the sketch specific fields, named after the fields of the sketch's params struct, eg {"crackLimit": 20}
+type Params struct {
+	Width      int             `json:"width"`
+	Height     int             `json:"height"`
+	Seed       int64           `json:"seed"`
+	Iterations *int            `json:"iterations"`
+	URL        string          `json:"url"`
+	Palette    [][3]int        `json:"palette"`
+	Params     json.RawMessage `json:"params"`
+}
+
+type job struct {
+	ctx    context.Context
+	name   string
+	params interface{}
+	opts   sketch.RenderOptions
+	url    string
+	done   chan result
+}
+
+type result struct {
+	png []byte
+	err error
+}
+
+// New creates a server, call Start to begin rendering
+func New(config Config) *Server {
+	return &Server{
+		Config: config,
+		jobs:   make(chan *job, config.QueueSize),
+		cache:  newCache(config.CacheSize),
+	}
+}
+
+// Start launches the workers
+func (s *Server) Start() {
+	for i := 0; i < s.Workers; i++ {
+		go s.work()
+	}
+}
+
+func (s *Server) work() {
+	for j := range s.jobs {
+		// the client already gave up, so skip the work
+		if j.ctx.Err() != nil {
+			continue
+		}
+		j.done <- s.render(j)
+	}
+}
+
+func (s *Server) render(j *job) (res result) {
+	// a bad combination of params can panic deep inside a sketch, that should only fail the one request
+	defer func() {
+		if r := recover(); r != nil {
+			res = result{err: fmt.Errorf("render failed: %v", r)}
+		}
+	}()
+
+	// some sketches only use an image in some modes, so load one whenever a url is given
+	if sketch.Renderers[j.name].Source || j.url != "" {
+		img, err := util.LoadUnsplashImage(j.opts.Width, j.opts.Height, j.url)
+		if err != nil {
+			return result{err: err}
+		}
+		j.opts.Source = img
+	}
+
+	img, err := sketch.Render(j.name, j.params, j.opts)
+	if err != nil {
+		return result{err: err}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return result{err: err}
+	}
+	return result{png: buf.Bytes()}
+}
+
+// ServeHTTP handles GET /sketches and POST /render/{sketch}
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/sketches" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sketch.SketchNames())
+	case strings.HasPrefix(r.URL.Path, "/render/"):
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleRender(w, r, strings.TrimPrefix(r.URL.Path, "/render/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleRender(w http.ResponseWriter, r *http.Request, name string) {
+	renderer, ok := sketch.Renderers[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown sketch %q", name), http.StatusNotFound)
+		return
+	}
+
+	// params are checked against the canvas once they're read, but reading them has to be bounded too
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	req := Params{Width: 1920, Height: 1080}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Width <= 0 || req.Height <= 0 || req.Width > s.MaxWidth || req.Height > s.MaxHeight {
+		http.Error(w, fmt.Sprintf("width and height must be between 1 and %dx%d", s.MaxWidth, s.MaxHeight), http.StatusBadRequest)
+		return
+	}
+
+	params := renderer.Params(req.Width, req.Height, req.Palette)
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, params); err != nil {
+			http.Error(w, "invalid params: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	iterations := renderer.Iterations
+	if req.Iterations != nil {
+		iterations = *req.Iterations
+	}
+	if iterations < 0 || iterations > s.MaxIterations {
+		http.Error(w, fmt.Sprintf("iterations must be between 0 and %d", s.MaxIterations), http.StatusBadRequest)
+		return
+	}
+
+	// only repeatable renders are worth caching: a seed is required, and a random photo is never the same twice
+	key := ""
+	if req.Seed != 0 && (!renderer.Source || req.URL != "") {
+		key = hashRequest(name, req, iterations, params)
+		if png, ok := s.cache.get(key); ok {
+			writePNG(w, png, req.Seed, "HIT")
+			return
+		}
+	}
+
+	// pick the seed here rather than leaving it to the sketch, so an unseeded render can still be repeated from X-Seed
+	seed := req.Seed
+	for seed == 0 {
+		seed = rand.Int63()
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+
+	j := &job{
+		ctx:    ctx,
+		name:   name,
+		params: params,
+		opts:   sketch.RenderOptions{Width: req.Width, Height: req.Height, Iterations: iterations, Seed: seed, Context: ctx},
+		url:    req.URL,
+		done:   make(chan result, 1),
+	}
+
+	select {
+	case s.jobs <- j:
+	default:
+		http.Error(w, "render queue is full", http.StatusServiceUnavailable)
+		return
+	}
+
+	select {
+	case res := <-j.done:
+		if res.err != nil {
+			http.Error(w, res.err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if key != "" {
+			s.cache.add(key, res.png)
+		}
+		writePNG(w, res.png, seed, "MISS")
+	case <-ctx.Done():
+		// the worker stops the sketch at its next iteration or step and moves on
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			http.Error(w, "render timed out", http.StatusGatewayTimeout)
+		}
+	}
+}
+
+func writePNG(w http.ResponseWriter, png []byte, seed int64, cacheStatus string) {
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(png)))
+	w.Header().Set("X-Seed", strconv.FormatInt(seed, 10))
+	w.Header().Set("X-Cache", cacheStatus)
+	w.Write(png)
+}
+
+// hashRequest identifies a render by everything that affects its output
+// The params are hashed after defaults are applied, so leaving a field out and sending its default are the same request.
+func hashRequest(name string, req Params, iterations int, params interface{}) string {
+	encoded, _ := json.Marshal(params)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%d\n%d\n%d\n%d\n%s\n", name, req.Width, req.Height, req.Seed, iterations, req.URL)
+	h.Write(encoded)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cache is a small least recently used store of encoded pngs
+type cache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key string
+	png []byte
+}
+
+func newCache(size int) *cache {
+	return &cache{size: size, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *cache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*cacheEntry).png, true
+}
+
+func (c *cache) add(key string, png []byte) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, png: png})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
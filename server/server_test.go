@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testConfig() Config {
+	return Config{Workers: 1, QueueSize: 4, Timeout: time.Minute, CacheSize: 4, MaxWidth: 200, MaxHeight: 200, MaxIterations: 1000}
+}
+
+func post(s *Server, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body)))
+	return w
+}
+
+func TestRenderRejectsBadRequests(t *testing.T) {
+	s := New(testConfig())
+	s.Start()
+	for _, c := range []struct {
+		name, path, body string
+		want             int
+	}{
+		{"unknown sketch", "/render/nope", `{}`, http.StatusNotFound},
+		{"bad json", "/render/sun", `{"width": `, http.StatusBadRequest},
+		{"too wide", "/render/sun", `{"width": 201, "height": 20}`, http.StatusBadRequest},
+		{"too tall", "/render/sun", `{"width": 20, "height": 201}`, http.StatusBadRequest},
+		{"no width", "/render/sun", `{"width": 0, "height": 20}`, http.StatusBadRequest},
+		{"too many iterations", "/render/crack", `{"width": 20, "height": 20, "iterations": 1001}`, http.StatusBadRequest},
+		{"bad params", "/render/sun", `{"width": 20, "height": 20, "params": {"LineWidth": "wide"}}`, http.StatusBadRequest},
+		{"too many seeds", "/render/growth", `{"width": 20, "height": 20, "params": {"StartingSeeds": 1000000000}}`, http.StatusUnprocessableEntity},
+		{"too big", "/render/sun", `{"width": 20, "height": 20, "url": "` + strings.Repeat("x", maxBodySize) + `"}`, http.StatusBadRequest},
+	} {
+		if w := post(s, c.path, c.body); w.Code != c.want {
+			t.Errorf("%s: got %d, want %d: %s", c.name, w.Code, c.want, w.Body)
+		}
+	}
+}
+
+func TestRenderQueueFull(t *testing.T) {
+	config := testConfig()
+	config.QueueSize = 0
+	// no workers are started, so there's never room for the job
+	s := New(config)
+	if w := post(s, "/render/sun", `{"width": 20, "height": 20}`); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestRenderTimeout(t *testing.T) {
+	config := testConfig()
+	config.Timeout = 10 * time.Millisecond
+	// the job is queued but no worker picks it up
+	s := New(config)
+	if w := post(s, "/render/sun", `{"width": 20, "height": 20}`); w.Code != http.StatusGatewayTimeout {
+		t.Errorf("got %d, want %d", w.Code, http.StatusGatewayTimeout)
+	}
+}
+
+func TestRenderCachesSeededRequests(t *testing.T) {
+	s := New(testConfig())
+	s.Start()
+	body := `{"width": 40, "height": 30, "seed": 7}`
+
+	first := post(s, "/render/sun", body)
+	if first.Code != http.StatusOK || first.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("first render: got %d %q, want 200 MISS", first.Code, first.Header().Get("X-Cache"))
+	}
+	second := post(s, "/render/sun", body)
+	if second.Code != http.StatusOK || second.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("second render: got %d %q, want 200 HIT", second.Code, second.Header().Get("X-Cache"))
+	}
+	if !bytes.Equal(first.Body.Bytes(), second.Body.Bytes()) {
+		t.Error("cached png differs from the render")
+	}
+	if got := second.Header().Get("X-Seed"); got != "7" {
+		t.Errorf("got seed %q, want 7", got)
+	}
+
+	// a different seed is a different image
+	if w := post(s, "/render/sun", `{"width": 40, "height": 30, "seed": 8}`); w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("another seed: got %q, want MISS", w.Header().Get("X-Cache"))
+	}
+	// without a seed the render isn't repeatable, so it's never served from the cache
+	for i := 0; i < 2; i++ {
+		if w := post(s, "/render/sun", `{"width": 40, "height": 30}`); w.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("unseeded render %d: got %q, want MISS", i, w.Header().Get("X-Cache"))
+		}
+	}
+}
+
+func TestRenderReportsTheSeedItPicked(t *testing.T) {
+	s := New(testConfig())
+	s.Start()
+	unseeded := post(s, "/render/sun", `{"width": 40, "height": 30}`)
+	seed := unseeded.Header().Get("X-Seed")
+	if seed == "" || seed == "0" {
+		t.Fatalf("got seed %q, want the one the render used", seed)
+	}
+	// asking for that seed gives the same image back
+	seeded := post(s, "/render/sun", `{"width": 40, "height": 30, "seed": `+seed+`}`)
+	if !bytes.Equal(unseeded.Body.Bytes(), seeded.Body.Bytes()) {
+		t.Errorf("rendering seed %s again gave a different image", seed)
+	}
+}
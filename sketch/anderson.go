@@ -1,11 +1,11 @@
 package sketch
 
 import (
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"math"
-	"math/rand"
 
 	"github.com/fogleman/gg"
 	"github.com/teacat/noire"
@@ -13,7 +13,7 @@ import (
 )
 
 var (
-	andersonColors = [...][3]uint8{
+	andersonColors = [][3]int{
 		{224, 105, 99},
 		{119, 194, 169},
 		{45, 225, 100},
@@ -32,6 +32,55 @@ type AndersonParams struct {
 	DestWidth  int
 	DestHeight int
 	Iterations int
+	// Slots is the number of colored blocks across the horizon, 0 for one per color
+	Slots int
+	// LeftEdge and RightEdge are the widths of the dark shapes at either side, measured in slots
+	LeftEdge  float64
+	RightEdge float64
+	// Horizon is the height of the horizon as a fraction of the canvas from the top, 0 for a random height
+	Horizon float64
+	// Palette replaces the default block colors when it is not empty, colors repeat when there are more slots than colors
+	Palette [][3]int
+	// WaterScale is how much longer a block's reflection is than the block
+	WaterScale float64
+	// RippleSpacing is the distance between ripples in the water, in pixels
+	RippleSpacing int
+	// Painterly adds bristle strokes to the sky blocks and paints the water as a reflection of the sky
+	Painterly bool
+	// WaveAmplitude is how far waves shift the reflection sideways, in pixels
+	WaveAmplitude float64
+	// WaveLength is the distance between wave crests in the reflection, in pixels
+	WaveLength float64
+	// ReflectionBlur is the vertical blur radius of the reflection at the horizon, in pixels, it doubles towards the bottom
+	ReflectionBlur int
+	Random
+}
+
+// Validate rejects negative sizes, more slots than columns of pixels, a horizon off the canvas and ripples or waves with no spacing
+func (p AndersonParams) Validate() error {
+	// a slot is at least a pixel wide
+	if p.Slots < 0 || p.Slots > p.DestWidth {
+		return errors.New("slots must be between 0 and the width of the canvas")
+	}
+	if p.LeftEdge < 0 || p.RightEdge < 0 {
+		return errors.New("edge widths can't be negative")
+	}
+	if p.Horizon < 0 || p.Horizon >= 1 {
+		return errors.New("horizon must be between 0 and 1")
+	}
+	if p.WaterScale < 0 {
+		return errors.New("water scale can't be negative")
+	}
+	if p.RippleSpacing <= 0 {
+		return errors.New("ripple spacing must be positive")
+	}
+	if p.Painterly && p.WaveLength <= 0 {
+		return errors.New("wave length must be positive")
+	}
+	if p.ReflectionBlur < 0 {
+		return errors.New("reflection blur can't be negative")
+	}
+	return nil
 }
 
 // AndersonSketch wraps all the components needed to draw the spiral sketch
@@ -41,7 +90,12 @@ type AndersonSketch struct {
 	currentR    float64
 	horizon     int // also max height of each slot
 	slot        float64
-	slotOffsets [6]int
+	left        float64 // width of the left edge, where the first slot starts
+	slotOffsets []int
+	// colors holds the color of each slot
+	colors [][3]int
+	// noise roughens the waves of the painterly reflection
+	noise *util.Noise
 }
 
 // NewAndersonSketch initializes the canvas and AndersonSketch
@@ -56,9 +110,23 @@ func NewAndersonSketch(params AndersonParams) *AndersonSketch {
 	fmt.Println("Starting Sketch")
 
 	s := &AndersonSketch{AndersonParams: params, currentR: 2.0}
-	s.horizon = util.RandIntRangeFrom(s.DestHeight/5, s.DestHeight*4/5)
-	//s.horizon = 300
-	s.slot = float64(s.DestWidth) / 8.0
+	s.Rand = randSource(s.Rand)
+	if s.Horizon > 0 {
+		s.horizon = int(s.Horizon * float64(s.DestHeight))
+	} else {
+		s.horizon = util.RandIntRangeFrom(s.Rand, s.DestHeight/5, s.DestHeight*4/5)
+	}
+
+	palette := andersonColors
+	if len(s.Palette) > 0 {
+		palette = s.Palette
+	}
+	slots := s.Slots
+	if slots == 0 {
+		slots = len(palette)
+	}
+	s.slot = float64(s.DestWidth) / (float64(slots) + s.LeftEdge + s.RightEdge)
+	s.left = s.LeftEdge * s.slot
 
 	// canvas is a gg image context and contains what gets drawn to the screen
 	canvas := gg.NewContext(s.DestWidth, s.DestHeight)
@@ -75,14 +143,20 @@ func NewAndersonSketch(params AndersonParams) *AndersonSketch {
 	canvas.Stroke()
 	s.DC = canvas
 
-	rand.Shuffle(len(andersonColors), func(i, j int) {
-		andersonColors[i], andersonColors[j] = andersonColors[j], andersonColors[i]
+	// shuffle a copy so that every sketch starts from the same order for a given seed
+	shuffled := append([][3]int(nil), palette...)
+	s.Rand.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
 	})
+	s.colors = make([][3]int, slots)
+	for j := range s.colors {
+		s.colors[j] = shuffled[j%len(shuffled)]
+	}
 
 	// slot offsets, 1 for left and -1 for right
-	slotOffsets := [6]int{}
-	for j := 0; j < len(andersonColors); j++ {
-		if rand.Intn(100) > 50 {
+	slotOffsets := make([]int, slots)
+	for j := 0; j < slots; j++ {
+		if s.Rand.Intn(100) > 50 {
 			slotOffsets[j] = 1
 		} else {
 			slotOffsets[j] = -1
@@ -90,6 +164,10 @@ func NewAndersonSketch(params AndersonParams) *AndersonSketch {
 	}
 	s.slotOffsets = slotOffsets
 
+	if s.Painterly {
+		s.noise = util.NewNoise(s.Rand)
+	}
+
 	return s
 }
 
@@ -100,17 +178,17 @@ func (s *AndersonSketch) Output() image.Image {
 
 // Update makes a logical step into generation
 func (s *AndersonSketch) Update(i int) {
-	for j := 0; j < len(andersonColors); j++ {
-		acolor := andersonColors[j]
+	for j := 0; j < len(s.colors); j++ {
+		acolor := s.colors[j]
 
-		x := s.slot + float64(j)*s.slot
+		x := s.left + float64(j)*s.slot
 		y := float64(s.horizon)
 
 		maxWidth := float64(s.slot) / float64(i+1)
 		nextStepWidth := float64(s.slot) / float64(i+2)
 		w := maxWidth
 		if i != 0 {
-			w = util.RandFloat64RangeFrom(nextStepWidth+(maxWidth-nextStepWidth)/2, maxWidth)
+			w = util.RandFloat64RangeFrom(s.Rand, nextStepWidth+(maxWidth-nextStepWidth)/2, maxWidth)
 		}
 		// push the starting place to the right if selected at initilization
 		if s.slotOffsets[j] == -1 {
@@ -119,7 +197,7 @@ func (s *AndersonSketch) Update(i int) {
 
 		maxHeight := y / float64(i+1)
 		nextStepHeight := y / float64(i+2)
-		h := util.RandFloat64RangeFrom(nextStepHeight, maxHeight)
+		h := util.RandFloat64RangeFrom(s.Rand, nextStepHeight, maxHeight)
 
 		// gradient is two circles: first is the solid color and is the smaller of the two
 		// second is the transparent color and is larger
@@ -127,7 +205,7 @@ func (s *AndersonSketch) Update(i int) {
 		// transparent color is 100% outside the second circle.
 		// Ensure the first circle is entirely below the horizon, so that the base is a solid color.
 		// Jitter left and right and radius of larger circle for some variation
-		grad := gg.NewRadialGradient(x+w/2, y+5, 5, x+w/2+util.RandFloat64Range(5), y+5, h+util.RandFloat64Range(5))
+		grad := gg.NewRadialGradient(x+w/2, y+5, 5, x+w/2+util.RandFloat64Range(s.Rand, 5), y+5, h+util.RandFloat64Range(s.Rand, 5))
 
 		alpha := util.MinFloat64(0.2+0.2*float64(i), 1.0)
 		solid := color.RGBA{}
@@ -150,6 +228,10 @@ func (s *AndersonSketch) Update(i int) {
 		s.DC.Fill()
 		s.DC.Stroke()
 
+		if s.Painterly {
+			s.drawBristles(acolor, x, y, w, h, alpha)
+		}
+
 		// water mirror
 		wgrad := gg.NewRadialGradient(x+w/2, y-5, 5, x+w/2, y-5, h)
 		wcolor := noire.NewRGB(float64(acolor[0]), float64(acolor[1]), float64(acolor[2]))
@@ -171,11 +253,13 @@ func (s *AndersonSketch) Update(i int) {
 		wgrad.AddColorStop(0, wsolid)
 		wgrad.AddColorStop(1, wtransparent)
 
-		waterScale := 1.4
-		s.DC.SetFillStyle(wgrad)
-		s.DC.DrawRectangle(x, y, w, h*waterScale)
-		s.DC.Fill()
-		s.DC.Stroke()
+		// the painterly reflection is drawn from the finished sky instead
+		if !s.Painterly {
+			s.DC.SetFillStyle(wgrad)
+			s.DC.DrawRectangle(x, y, w, h*s.WaterScale)
+			s.DC.Fill()
+			s.DC.Stroke()
+		}
 
 		// if this is the last iteration, we are going to put some full strength color and near black blocks in the front
 		// and draw the sides
@@ -186,13 +270,13 @@ func (s *AndersonSketch) Update(i int) {
 			// 2 = near-black
 			// 3 = same
 			options := [...]int{0, 1, 2, 2, 2, 2, 3}
-			rand.Shuffle(len(options), func(i, j int) {
+			s.Rand.Shuffle(len(options), func(i, j int) {
 				options[i], options[j] = options[j], options[i]
 			})
 
 			lcolor := noire.NewRGB(float64(acolor[0]), float64(acolor[1]), float64(acolor[2]))
 			// reset
-			x = s.slot + float64(j)*s.slot
+			x = s.left + float64(j)*s.slot
 			h := nextStepHeight
 			down := y
 			wi := math.Round(s.slot / 3)
@@ -202,7 +286,7 @@ func (s *AndersonSketch) Update(i int) {
 			for t := 0; t < 3; t++ {
 				left := math.Round(x + float64(t)*s.slot/3)
 				if t == 1 {
-					hj = util.RandFloat64Range(he / 5)
+					hj = util.RandFloat64Range(s.Rand, he/5)
 				} else {
 					hj = 0.0
 				}
@@ -237,30 +321,123 @@ func (s *AndersonSketch) Update(i int) {
 			// left
 			s.DC.SetRGBA255(dark[0], dark[1], dark[2], 255)
 			s.DC.MoveTo(0, float64(s.horizon))
-			s.DC.LineTo(s.slot, float64(s.horizon))
-			s.DC.LineTo(s.slot, float64(s.horizon)+he)
+			s.DC.LineTo(s.left, float64(s.horizon))
+			s.DC.LineTo(s.left, float64(s.horizon)+he)
 			s.DC.LineTo(0, float64(s.horizon)+2*he)
 			s.DC.ClosePath()
 			s.DC.Fill()
 			s.DC.Stroke()
 			// right
 			s.DC.SetRGBA255(dark[0], dark[1], dark[2], 255)
-			s.DC.MoveTo(float64(s.DestWidth)-s.slot, float64(s.horizon))
+			right := s.RightEdge * s.slot
+			s.DC.MoveTo(float64(s.DestWidth)-right, float64(s.horizon))
 			s.DC.LineTo(float64(s.DestWidth), float64(s.horizon))
 			s.DC.LineTo(float64(s.DestWidth), float64(s.horizon)+2*he)
-			s.DC.LineTo(float64(s.DestWidth)-s.slot, float64(s.horizon)+he)
+			s.DC.LineTo(float64(s.DestWidth)-right, float64(s.horizon)+he)
 			s.DC.ClosePath()
 			s.DC.Fill()
 			s.DC.Stroke()
 
-			// add ripples
-			ripples := (s.DestHeight - s.horizon) / 100
-			for k := 0; k < ripples; k++ {
-				s.DC.SetRGBA255(dark[0], dark[1], dark[2], 10)
-				s.DC.DrawRectangle(0, float64(s.horizon+100*k)+util.RandFloat64Range(5.0), float64(s.DestWidth), 10+util.RandFloat64Range(3))
-				s.DC.Fill()
-				s.DC.Stroke()
+			if !s.Painterly {
+				s.drawRipples()
+			}
+		}
+	}
+
+	if s.Painterly && i == s.Iterations {
+		s.drawReflection()
+		s.drawRipples()
+	}
+}
+
+// drawRipples lays faint dark bands across the water
+func (s *AndersonSketch) drawRipples() {
+	ripples := (s.DestHeight - s.horizon) / s.RippleSpacing
+	for k := 0; k < ripples; k++ {
+		s.DC.SetRGBA255(dark[0], dark[1], dark[2], 10)
+		s.DC.DrawRectangle(0, float64(s.horizon+s.RippleSpacing*k)+util.RandFloat64Range(s.Rand, 5.0), float64(s.DestWidth), 10+util.RandFloat64Range(s.Rand, 3))
+		s.DC.Fill()
+		s.DC.Stroke()
+	}
+}
+
+// drawBristles drags thin strokes up through a sky block, like the marks of a dry brush
+// Each stroke is a little lighter or darker than the block and about as opaque as this iteration's paint.
+func (s *AndersonSketch) drawBristles(acolor [3]int, x, y, w, h, alpha float64) {
+	base := noire.NewRGB(float64(acolor[0]), float64(acolor[1]), float64(acolor[2]))
+	strokes := int(w/2) + 1
+	for k := 0; k < strokes; k++ {
+		c := base.Lighten(util.RandFloat64Range(s.Rand, 0.1))
+		if s.Rand.Intn(2) == 0 {
+			c = base.Darken(util.RandFloat64Range(s.Rand, 0.1))
+		}
+		r, g, b := c.RGB()
+		s.DC.SetRGBA255(int(r), int(g), int(b), int(alpha*90))
+		s.DC.SetLineWidth(util.RandFloat64RangeFrom(s.Rand, 0.5, 1.5))
+
+		bx := x + s.Rand.Float64()*w
+		bottom := y - s.Rand.Float64()*h*0.3
+		top := bottom - h*util.RandFloat64RangeFrom(s.Rand, 0.2, 0.7)
+		slant := util.RandFloat64Range(s.Rand, w*0.05)
+		s.DC.DrawLine(bx, bottom, bx+slant, util.MaxFloat64(top, y-h))
+		s.DC.Stroke()
+	}
+	s.DC.SetLineWidth(0.0)
+}
+
+// drawReflection paints the water with the sky above it, mirrored at the horizon
+// The mirror is stretched by WaterScale, pushed sideways by sine waves roughened with noise,
+// blurred vertically more the further it is from the horizon, and mixed into the water color.
+func (s *AndersonSketch) drawReflection() {
+	img := s.DC.Image().(*image.RGBA)
+	w, horizon := s.DestWidth, s.horizon
+	depth := s.DestHeight - horizon
+	if horizon <= 0 || depth <= 0 {
+		return
+	}
+
+	// running sums down each column of the sky, so any vertical blur is two lookups
+	sums := make([][3]int, w*(horizon+1))
+	for x := 0; x < w; x++ {
+		for y := 0; y < horizon; y++ {
+			p := img.PixOffset(x, y)
+			prev := sums[y*w+x]
+			sums[(y+1)*w+x] = [3]int{prev[0] + int(img.Pix[p]), prev[1] + int(img.Pix[p+1]), prev[2] + int(img.Pix[p+2])}
+		}
+	}
+
+	scale := s.WaterScale
+	if scale <= 0 {
+		scale = 1
+	}
+	phase := s.Rand.Float64() * 2 * math.Pi
+	for d := 0; d < depth; d++ {
+		y := horizon + d
+		t := float64(d) / float64(depth)
+		// mirrored sky row, waves and blur grow with distance from the horizon
+		sy := horizon - 1 - int(float64(d)/scale)
+		if sy < 0 {
+			sy = 0
+		}
+		blur := int(float64(s.ReflectionBlur) * (1 + t))
+		top := util.MaxInt(sy-blur, 0)
+		bottom := util.MinInt(sy+blur+1, horizon)
+		n := bottom - top
+		amplitude := s.WaveAmplitude * (1 + t)
+		wave := math.Sin(2*math.Pi*float64(d)/s.WaveLength + phase)
+		// reflections fade into the water further from the horizon
+		mix := 0.7 - 0.4*t
+
+		for x := 0; x < w; x++ {
+			shift := amplitude * (wave + s.noise.At(float64(x)/s.WaveLength, float64(d)*4/s.WaveLength))
+			sx := util.MinInt(util.MaxInt(x+int(shift), 0), w-1)
+			a, b := sums[top*w+sx], sums[bottom*w+sx]
+			p := img.PixOffset(x, y)
+			for c := 0; c < 3; c++ {
+				sky := float64(b[c]-a[c]) / float64(n) * 0.8
+				img.Pix[p+c] = uint8(float64(water[c])*(1-mix) + sky*mix)
 			}
+			img.Pix[p+3] = 255
 		}
 	}
 }
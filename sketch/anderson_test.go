@@ -0,0 +1,44 @@
+package sketch
+
+import (
+	"image"
+	"testing"
+)
+
+func TestAndersonSlotsRepeatPalette(t *testing.T) {
+	palette := [][3]int{{255, 0, 0}, {0, 255, 0}}
+	s := NewAndersonSketch(AndersonParams{DestWidth: 200, DestHeight: 100, Slots: 5, Palette: palette, WaterScale: 1.4, RippleSpacing: 100})
+	if len(s.colors) != 5 || len(s.slotOffsets) != 5 {
+		t.Fatalf("got %d colors and %d offsets for 5 slots", len(s.colors), len(s.slotOffsets))
+	}
+	for j, c := range s.colors {
+		if c != s.colors[j%2] {
+			t.Errorf("slot %d has %v, want the palette to repeat", j, c)
+		}
+	}
+	if andersonColors[0] != [3]int{224, 105, 99} {
+		t.Error("the default colors were shuffled in place")
+	}
+}
+
+func TestAndersonPainterlyReflectsSky(t *testing.T) {
+	p := AndersonParams{DestWidth: 160, DestHeight: 100, Iterations: 3, Horizon: 0.5, LeftEdge: 1, RightEdge: 1, WaterScale: 1.4, RippleSpacing: 100, Painterly: true, WaveLength: 40, ReflectionBlur: 1}
+	s := NewAndersonSketch(p)
+	for i := 0; i <= p.Iterations; i++ {
+		s.Update(i)
+	}
+	img := s.Output().(*image.RGBA)
+	// without waves, a bright sky column should show up brighter in the water right below the horizon than a dark one
+	brightest, darkest := 0, 0
+	for x := 0; x < 160; x++ {
+		if luminance(img.At(x, 48)) > luminance(img.At(brightest, 48)) {
+			brightest = x
+		}
+		if luminance(img.At(x, 48)) < luminance(img.At(darkest, 48)) {
+			darkest = x
+		}
+	}
+	if luminance(img.At(brightest, 51)) <= luminance(img.At(darkest, 51)) {
+		t.Errorf("water under column %d isn't brighter than under column %d", brightest, darkest)
+	}
+}
@@ -0,0 +1,52 @@
+package sketch
+
+import (
+	"fmt"
+	"testing"
+)
+
+// benchmarkSizes are the canvas sizes every sketch is timed at
+var benchmarkSizes = []struct {
+	name          string
+	width, height int
+}{
+	{"360p", 640, 360},
+	{"1080p", 1920, 1080},
+}
+
+func BenchmarkSketches(b *testing.B) {
+	for _, name := range SketchNames() {
+		for _, size := range benchmarkSizes {
+			name, size := name, size
+			b.Run(fmt.Sprintf("%s/%s", name, size.name), func(b *testing.B) {
+				r := Renderers[name]
+				opts := RenderOptions{Width: size.width, Height: size.height, Iterations: r.Iterations, Seed: goldenSeed}
+				if r.Source {
+					opts.Source = testSource(size.width, size.height)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := Render(name, r.Params(size.width, size.height, nil), opts); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkFlip times flip at 4K with 40 divisions, where the many small tiles make the per-tile blit matter
+func BenchmarkFlip(b *testing.B) {
+	const width, height = 3840, 2160
+	params := Renderers["flip"].Params(width, height, nil).(*flipRenderParams)
+	params.Divisions = 40
+	opts := RenderOptions{Width: width, Height: height, Seed: goldenSeed, Source: testSource(width, height)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Render("flip", params, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,13 +1,12 @@
 package sketch
 
 import (
+	"errors"
 	"fmt"
 	"image"
-	"image/color"
 	"math"
-	"math/rand"
 
-	"github.com/fogleman/gg"
+	"gitlab.com/ericworkman/generative/blend"
 	"gitlab.com/ericworkman/generative/util"
 )
 
@@ -42,6 +41,80 @@ type CrackParams struct {
 	CrackLimit     int
 	Seeds          int
 	StartingCracks int
+	// Palette replaces the default sand colors when it is not empty
+	Palette [][3]int
+	// StepLength is how far a crack grows each update, in pixels
+	StepLength float64
+	// AngleJitter is the most a new crack strays from perpendicular to the one it starts on, in degrees
+	AngleJitter int
+	// RegionLimit caps how far sand spreads from a crack, as a fraction of the canvas size
+	RegionLimit float64
+	// SandAlpha is the alpha of each grain of sand, 0-255
+	SandAlpha int
+	// GrainModulation is the most the grain size of a sand painter changes each step
+	GrainModulation float64
+	// Curve bends cracks: straight, constant (arcs), drift (random walk) or noise (following a noise field)
+	Curve string
+	// Curvature is the most a crack turns each step, in degrees
+	Curvature float64
+	// NoiseScale is the size of features in the noise field used by the noise curve, in pixels
+	NoiseScale float64
+	// EdgeSource seeds cracks along the edges found in an image, running in the direction of each edge
+	EdgeSource image.Image `json:"-"`
+	// EdgeThreshold is the smallest edge strength that seeds a crack, between 0 and 1
+	EdgeThreshold float64
+	// EdgeDensity is the chance each edge pixel starts a run of seeds along its edge, between 0 and 1
+	EdgeDensity float64
+	// Mask keeps cracks and sand to the bright parts of an image
+	Mask image.Image `json:"-"`
+	// ColorSource colors the sand of each crack from an image at the point the crack starts
+	ColorSource image.Image `json:"-"`
+	// Blend is how sand combines with the canvas, one of blend.Modes
+	Blend string
+	HDROptions
+	Random
+}
+
+// CrackCurves are the accepted values of CrackParams.Curve
+var CrackCurves = []string{"straight", "constant", "drift", "noise"}
+
+// Validate rejects unknown curves and blend modes, counts over one per pixel, and steps, regions, densities and alphas out of
+// range
+func (p CrackParams) Validate() error {
+	if err := checkCount("crack limit", p.CrackLimit, p.DestWidth, p.DestHeight); err != nil {
+		return err
+	}
+	if err := checkCount("seeds", p.Seeds, p.DestWidth, p.DestHeight); err != nil {
+		return err
+	}
+	if err := checkCount("starting cracks", p.StartingCracks, p.DestWidth, p.DestHeight); err != nil {
+		return err
+	}
+	if p.StepLength <= 0 {
+		return errors.New("step length must be positive")
+	}
+	if p.AngleJitter < 0 || p.GrainModulation < 0 {
+		return errors.New("angle jitter and grain modulation can't be negative")
+	}
+	if p.RegionLimit <= 0 || p.RegionLimit > 1 {
+		return errors.New("region limit must be more than 0 and at most 1")
+	}
+	if p.SandAlpha < 0 || p.SandAlpha > 255 {
+		return errors.New("sand alpha must be between 0 and 255")
+	}
+	if err := checkOptionalChoice("curve", p.Curve, CrackCurves); err != nil {
+		return err
+	}
+	if p.EdgeSource != nil && (p.EdgeDensity <= 0 || p.EdgeDensity > 1) {
+		return errors.New("edge density must be more than 0 and at most 1")
+	}
+	if p.Curve == "noise" && p.NoiseScale <= 0 {
+		return errors.New("noise scale must be positive")
+	}
+	if _, err := blend.ParseMode(p.Blend); err != nil {
+		return err
+	}
+	return p.HDROptions.Validate()
 }
 
 // CrackSketch contains a canvas, a grid, a set of cracks, and some other information
@@ -51,10 +124,22 @@ type CrackSketch struct {
 	// There are a few spots with a couple changes mostly to fit into golang and gg.
 	// This hasn't been optimized and very likely has bugs, but it does produce nice results.
 	CrackParams
-	DC       *gg.Context
+	// canvas holds what gets drawn, at 16 bits per channel or as floats, so faint sand builds up smoothly
+	canvas   blend.Surface
+	mode     blend.Mode
 	GridSize int
 	Grid     []int
 	cracks   []crack
+	noise    *util.Noise
+	// occupied lists every grid cell holding an angle, so a new crack can start from one without searching
+	occupied []int
+	// masked is true for cells that cracks can't grow into, nil without a mask
+	masked []bool
+}
+
+// isMasked reports whether the mask blocks a cell
+func (s *CrackSketch) isMasked(i int) bool {
+	return s.masked != nil && s.masked[i]
 }
 
 type crack struct {
@@ -63,39 +148,92 @@ type crack struct {
 	Y  float64
 	T  float64 // direction in degrees
 	SP sandPainter
+	// turn is the change in direction per step of a constant curve
+	turn float64
+}
+
+// normalizeAngle wraps degrees into [0, 360)
+func normalizeAngle(a float64) float64 {
+	a = math.Mod(a, 360)
+	if a < 0 {
+		a += 360
+	}
+	return a
+}
+
+// angleDiff is the smallest difference between two directions in degrees
+func angleDiff(a, b float64) float64 {
+	d := math.Abs(normalizeAngle(a) - normalizeAngle(b))
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
+
+// bend turns the crack according to the sketch's curve mode
+func (c *crack) bend(sketch *CrackSketch) {
+	switch sketch.Curve {
+	case "constant":
+		c.T += c.turn
+	case "drift":
+		c.T += util.RandFloat64Range(sketch.Rand, sketch.Curvature)
+	case "noise":
+		c.T += sketch.Curvature * sketch.noise.At(c.X/sketch.NoiseScale, c.Y/sketch.NoiseScale)
+	}
+	c.T = normalizeAngle(c.T)
+}
+
+// angleTolerance is how different a grid cell's angle may be from the crack's and still be the crack's own trail
+// A curved crack turns between writing a cell and coming back to it, so allow for a few steps of turning.
+func (s *CrackSketch) angleTolerance() float64 {
+	if s.Curve == "" || s.Curve == "straight" {
+		return 5.0
+	}
+	return 5.0 + 3*math.Abs(s.Curvature)
 }
 
 // Grow a crack it its direction t. Color to the side of it some distance using the sandPainter.
 func (c *crack) Move(sketch *CrackSketch) {
-	c.X += 0.42 * math.Cos(c.T*math.Pi/180)
-	c.Y += 0.42 * math.Sin(c.T*math.Pi/180)
+	c.bend(sketch)
+	c.X += sketch.StepLength * math.Cos(c.T*math.Pi/180)
+	c.Y += sketch.StepLength * math.Sin(c.T*math.Pi/180)
 
 	// bound check
 	z := 0.25
-	cx := int(c.X + util.RandFloat64Range(z))
-	cy := int(c.Y + util.RandFloat64Range(z))
+	cx := int(c.X + util.RandFloat64Range(sketch.Rand, z))
+	cy := int(c.Y + util.RandFloat64Range(sketch.Rand, z))
 
 	// draw sand painter
 	c.RegionColor(sketch)
 
-	// draw black crack
-	sketch.DC.SetRGBA255(0, 0, 0, 180)
-
+	// black crack position
 	// TODO: replace jitter
-	x := int(c.X + util.RandFloat64Range(z))
-	y := int(c.Y + util.RandFloat64Range(z))
-	sketch.DC.SetPixel(x, y)
-
-	sketch.DC.Stroke()
+	x := int(c.X + util.RandFloat64Range(sketch.Rand, z))
+	y := int(c.Y + util.RandFloat64Range(sketch.Rand, z))
 
 	if (cx >= 0) && (cy >= 0) && (cx < sketch.DestWidth) && (cy < sketch.DestHeight) {
 		// within bounds of canvas
 
-		if (sketch.Grid[cy*sketch.DestWidth+cx] > 10000) || (math.Abs(float64(sketch.Grid[cy*sketch.DestWidth+cx])-c.T) < 5.0) {
+		if sketch.isMasked(cy*sketch.DestWidth + cx) {
+			// ran into the mask, so this crack ends like it does at the edge of the canvas, without drawing into it
+			c.findStart(sketch)
+			makecrack(sketch)
+			return
+		}
+
+		// draw black crack
+		sketch.canvas.Blend(x, y, [3]int{0, 0, 0}, 180.0/255, blend.Normal)
+
+		// the angle checks below go around the circle, so a trail written as 355 runs the same way as a crack at -5
+		// and a straight crack meeting it carries on, where comparing the raw numbers used to end it
+		if (sketch.Grid[cy*sketch.DestWidth+cx] > 10000) || (angleDiff(float64(sketch.Grid[cy*sketch.DestWidth+cx]), c.T) < sketch.angleTolerance()) {
 			// continue growing
+			if sketch.Grid[cy*sketch.DestWidth+cx] > 10000 {
+				sketch.occupied = append(sketch.occupied, cy*sketch.DestWidth+cx)
+			}
 			sketch.Grid[cy*sketch.DestWidth+cx] = int(c.T)
 
-		} else if math.Abs(float64(sketch.Grid[cy*sketch.DestWidth+cx])-c.T) > 2.0 {
+		} else if angleDiff(float64(sketch.Grid[cy*sketch.DestWidth+cx]), c.T) > 2.0 {
 			// found a different crack, so this crack ends
 			c.findStart(sketch)
 			makecrack(sketch)
@@ -109,34 +247,37 @@ func (c *crack) Move(sketch *CrackSketch) {
 }
 
 func (c *crack) findStart(sketch *CrackSketch) {
-	// pick random spots on the canvas until a crack is found
-	// a crack is any cell on the grid with a degree value between -360 and 360, or really less than the blank value
-	// limit the number of times this attempts to find a crack with the timeout
+	// pick a random cell that already holds a crack
+	// a crack is any cell on the grid with a degree value, or really less than the blank value
+	// every one of those is kept in the occupied list, so this only fails when there are no cracks at all
 	var px, py int
 	found := false
-	timeout := 0
-	for ok := true; ok; ok = ((found == false) && (timeout <= 10000)) {
-		timeout++
-		px = rand.Intn(sketch.DestWidth)
-		py = rand.Intn(sketch.DestHeight)
-		if sketch.Grid[py*sketch.DestWidth+px] < 10000 {
-			found = true
-		}
+	if len(sketch.occupied) > 0 {
+		i := sketch.occupied[sketch.Rand.Intn(len(sketch.occupied))]
+		px = i % sketch.DestWidth
+		py = i / sketch.DestWidth
+		found = true
 	}
 
 	if found == true {
 		// found a starting point, so now pick a perpendicular angle to the existing crack angle
 		// we add some angle jitter here too for interest
 		a := sketch.Grid[py*sketch.DestWidth+px]
-		if rand.Intn(100) < 50 {
-			a -= 90 + util.RandRange(3)
+		if sketch.Rand.Intn(100) < 50 {
+			a -= 90 + util.RandRange(sketch.Rand, sketch.AngleJitter)
 		} else {
-			a += 90 + util.RandRange(3)
+			a += 90 + util.RandRange(sketch.Rand, sketch.AngleJitter)
+		}
+		c.T = normalizeAngle(float64(a))
+		if sketch.Curve == "constant" {
+			c.turn = sketch.Curvature * util.RandFloat64RangeFrom(sketch.Rand, 0.5, 1)
+			if sketch.Rand.Intn(2) == 0 {
+				c.turn = -c.turn
+			}
 		}
-		c.T = float64(a)
 		c.X = float64(px) // + 0.61 * math.Cos(crack.T * math.Pi / 180)
 		c.Y = float64(py) // + 0.61 * math.Sin(crack.T * math.Pi / 180)
-		c.SP = newsandPainter()
+		c.SP = newsandPainter(sketch, c.X, c.Y)
 	}
 }
 
@@ -155,6 +296,12 @@ func NewCrackSketch(crackParams CrackParams) *CrackSketch {
 	fmt.Println("Starting Sketch")
 
 	s := &CrackSketch{CrackParams: crackParams}
+	s.Rand = randSource(s.Rand)
+	// an unknown mode is caught by Validate, and drawn as normal here
+	s.mode, _ = blend.ParseMode(s.Blend)
+	if s.Curve == "noise" {
+		s.noise = util.NewNoise(s.Rand)
+	}
 
 	// the grid is dimensionally the same as the canvas, but contains angles in degrees or a blank value
 	cgrid := make([]int, s.DestWidth*s.DestHeight)
@@ -163,10 +310,31 @@ func NewCrackSketch(crackParams CrackParams) *CrackSketch {
 		cgrid[i] = blankAngle
 	}
 
+	if s.Mask != nil {
+		// dark parts of the mask are off limits
+		s.masked = make([]bool, s.GridSize)
+		for i, l := range luminanceGrid(s.Mask, s.DestWidth, s.DestHeight) {
+			s.masked[i] = l < 0.5
+		}
+	}
+
+	// the canvas comes before the seeds, so edge seeds can be drawn as they're laid
+	s.canvas = newSurface(s.DestWidth, s.DestHeight, [3]int{255, 255, 255}, s.HDROptions)
+
+	if s.EdgeSource != nil {
+		s.seedEdges(cgrid)
+	}
+
 	// preseed some spots in the grid with real angles
 	for k := 0; k < s.Seeds; k++ {
-		i := rand.Intn(s.DestWidth*s.DestHeight - 1)
-		cgrid[i] = rand.Intn(360)
+		i := s.Rand.Intn(s.DestWidth*s.DestHeight - 1)
+		if s.isMasked(i) {
+			continue
+		}
+		if cgrid[i] == blankAngle {
+			s.occupied = append(s.occupied, i)
+		}
+		cgrid[i] = s.Rand.Intn(360)
 	}
 
 	s.Grid = cgrid
@@ -176,18 +344,80 @@ func NewCrackSketch(crackParams CrackParams) *CrackSketch {
 		makecrack(s)
 	}
 
-	// canvas is a gg image context and contains what gets drawn to the screen
-	canvas := gg.NewContext(s.DestWidth, s.DestHeight)
-	canvas.SetColor(color.White)
-	canvas.DrawRectangle(0, 0, float64(s.DestWidth), float64(s.DestHeight))
-	canvas.FillPreserve()
-	s.DC = canvas
 	return s
 }
 
+// edgeBend is the most an edge can turn from one pixel to the next and still be followed by one run of seeds
+const edgeBend = 30.0
+
+// seedEdges lays cracks along the edges in EdgeSource
+// Each edge pixel starts a run with EdgeDensity chance. The run follows the edge both ways, giving each cell the angle
+// of the edge there, and is drawn like a crack. New cracks then branch off and stop against whole lines, not single
+// pixels. The gradient points across an edge, so the crack angle is a quarter turn from it.
+func (s *CrackSketch) seedEdges(cgrid []int) {
+	lum := luminanceGrid(s.EdgeSource, s.DestWidth, s.DestHeight)
+	strong := make([]bool, len(cgrid))
+	angles := make([]float64, len(cgrid))
+	for y := 0; y < s.DestHeight; y++ {
+		for x := 0; x < s.DestWidth; x++ {
+			i := y*s.DestWidth + x
+			gx, gy := sobel(lum, s.DestWidth, s.DestHeight, x, y)
+			strong[i] = !s.isMasked(i) && math.Hypot(gx, gy)/maxSobel >= s.EdgeThreshold
+			angles[i] = normalizeAngle(math.Atan2(gy, gx)*180/math.Pi + 90)
+		}
+	}
+
+	for i := range cgrid {
+		if !strong[i] || cgrid[i] != blankAngle || s.Rand.Float64() >= s.EdgeDensity {
+			continue
+		}
+		s.seedCell(cgrid, i, angles[i])
+		s.followEdge(cgrid, strong, angles, i, angles[i])
+		s.followEdge(cgrid, strong, angles, i, angles[i]+180)
+	}
+}
+
+// followEdge seeds cells a pixel at a time from cell i in the heading, until the edge fades, turns too sharply or meets
+// another run
+func (s *CrackSketch) followEdge(cgrid []int, strong []bool, angles []float64, i int, heading float64) {
+	x := float64(i%s.DestWidth) + 0.5
+	y := float64(i/s.DestWidth) + 0.5
+	for {
+		x += math.Cos(heading * math.Pi / 180)
+		y += math.Sin(heading * math.Pi / 180)
+		if x < 0 || y < 0 || x >= float64(s.DestWidth) || y >= float64(s.DestHeight) {
+			return
+		}
+		j := int(y)*s.DestWidth + int(x)
+		if j == i {
+			// a diagonal step can stay in the same cell
+			continue
+		}
+		// the edge's angle could point either way along it, so take the one closer to the heading
+		a := angles[j]
+		if angleDiff(a, heading) > 90 {
+			a = normalizeAngle(a + 180)
+		}
+		if !strong[j] || cgrid[j] != blankAngle || angleDiff(a, heading) > edgeBend {
+			return
+		}
+		s.seedCell(cgrid, j, a)
+		i, heading = j, a
+	}
+}
+
+// seedCell gives a cell an angle and draws it as part of a crack
+func (s *CrackSketch) seedCell(cgrid []int, i int, angle float64) {
+	if cgrid[i] == blankAngle {
+		s.occupied = append(s.occupied, i)
+	}
+	cgrid[i] = int(angle)
+	s.canvas.Blend(i%s.DestWidth, i/s.DestWidth, [3]int{0, 0, 0}, 180.0/255, blend.Normal)
+}
+
 // Output creates the image from the canvas
 func (s *CrackSketch) Output() image.Image {
-	return s.DC.Image()
+	return s.canvas.Image()
 }
 
 // Update iterates through all of the cracks
@@ -207,17 +437,25 @@ type sandPainter struct {
 	GrainSize float64
 }
 
-func newsandPainter() sandPainter {
+func newsandPainter(s *CrackSketch, x, y float64) sandPainter {
 	// aim for desert colors, a slight departure from Tarbell's
-	// Tarbell's version takes colors from an image, while this one selects from a predefined list of colors
-	color := crackColors[rand.Intn(len(crackColors))]
-	sp := sandPainter{R: color[0], G: color[1], B: color[2], GrainSize: util.RandFloat64RangeFrom(0.01, 0.01)}
+	// Tarbell's version takes colors from an image, which this one does too when given a color source
+	// otherwise it selects from a predefined list of colors
+	var color [3]int
+	if s.ColorSource != nil {
+		color[0], color[1], color[2] = util.Rgb255(scaledAt(s.ColorSource, x, y, s.DestWidth, s.DestHeight))
+	} else if len(s.Palette) > 0 {
+		color = s.Palette[s.Rand.Intn(len(s.Palette))]
+	} else {
+		color = crackColors[s.Rand.Intn(len(crackColors))]
+	}
+	sp := sandPainter{R: color[0], G: color[1], B: color[2], GrainSize: util.RandFloat64RangeFrom(s.Rand, 0.01, 0.01)}
 	return sp
 }
 
 func (sp *sandPainter) render(s *CrackSketch, x, y, ox, oy float64) {
 	// modulate gain, clamping it between 0 and 1.0
-	sp.GrainSize += util.RandFloat64Range(0.050)
+	sp.GrainSize += util.RandFloat64Range(s.Rand, s.GrainModulation)
 	maxg := 1.0
 	if sp.GrainSize < 0 {
 		sp.GrainSize = 0
@@ -228,19 +466,26 @@ func (sp *sandPainter) render(s *CrackSketch, x, y, ox, oy float64) {
 
 	// proportion grain count for smoothness
 	grains := int(math.Sqrt(float64((ox-x)*(ox-x) + (oy-y)*(oy-y))))
+	// grains are spaced over grains-1 gaps, a single grain has none to space it by
+	if grains < 2 {
+		return
+	}
 
 	// draw the sand grains
+	// grains go straight into the canvas' pixels, the gg path for a point each was most of the sketch's running time
+	a := float64(s.SandAlpha) / 255 * grainArea
+	c := [3]int{sp.R, sp.G, sp.B}
 	w := sp.GrainSize / float64(grains-1)
 	for i := 0; i < grains; i++ {
-		a := 7
 		x := ox + (x-ox)*math.Sin(math.Sin(float64(i)*w))
 		y := oy + (y-oy)*math.Sin(math.Sin(float64(i)*w))
-		s.DC.SetRGBA255(sp.R, sp.G, sp.B, a)
-		s.DC.DrawPoint(x, y, 0.6)
-		s.DC.Stroke()
+		blend.Splat(s.canvas, x, y, c, a, s.mode)
 	}
 }
 
+// grainArea is the area of the 0.6 pixel radius dot the grains used to be drawn as
+const grainArea = math.Pi * 0.6 * 0.6
+
 func (c *crack) RegionColor(s *CrackSketch) {
 	// find the open region that can be colored that's perpendicular to the crack at the new pixel
 	// we use the boundary of this open space to determine how to draw the sand
@@ -256,8 +501,8 @@ func (c *crack) RegionColor(s *CrackSketch) {
 		cy := int(ry)
 
 		// limit the maximum size of the region to be within the bounds of the canvas and only a percent of the dimensions
-		if (cx >= 0) && (cy >= 0) && (cx < s.DestWidth) && (cy < s.DestHeight) && (int(math.Abs(float64(cx)-c.X)) < s.DestWidth/10) && (int(math.Abs(float64(cy)-c.Y)) < s.DestHeight/10) {
-			if s.Grid[cy*s.DestWidth+cx] <= 10000 {
+		if (cx >= 0) && (cy >= 0) && (cx < s.DestWidth) && (cy < s.DestHeight) && (math.Abs(float64(cx)-c.X) < s.RegionLimit*float64(s.DestWidth)) && (math.Abs(float64(cy)-c.Y) < s.RegionLimit*float64(s.DestHeight)) {
+			if s.Grid[cy*s.DestWidth+cx] <= 10000 || s.isMasked(cy*s.DestWidth+cx) {
 				openspace = false
 			}
 		} else {
@@ -0,0 +1,316 @@
+package sketch
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+
+	"gitlab.com/ericworkman/generative/blend"
+)
+
+func testCrackParams(width, height int) CrackParams {
+	return *Renderers["crack"].Params(width, height, nil).(*CrackParams)
+}
+
+func TestCrackMaskKeepsCracksInside(t *testing.T) {
+	// only the left half of the mask is bright
+	mask := image.NewGray(image.Rect(0, 0, 40, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 20; x++ {
+			mask.SetGray(x, y, color.Gray{255})
+		}
+	}
+
+	p := testCrackParams(80, 60)
+	p.Mask = mask
+	s := NewCrackSketch(p)
+	for i := 0; i < 2000; i++ {
+		s.Update()
+	}
+
+	for y := 0; y < p.DestHeight; y++ {
+		for x := p.DestWidth / 2; x < p.DestWidth; x++ {
+			if s.Grid[y*p.DestWidth+x] != blankAngle {
+				t.Fatalf("crack at %d, %d is outside the mask", x, y)
+			}
+		}
+	}
+}
+
+func TestCrackEndsWithoutDrawingIntoTheMask(t *testing.T) {
+	// everything is masked, so the first step a crack takes ends it
+	p := testCrackParams(80, 60)
+	p.Mask = image.NewGray(image.Rect(0, 0, 80, 60))
+	// without sand, the only thing a step can draw is the crack line
+	p.SandAlpha = 0
+	s := NewCrackSketch(p)
+
+	c := crack{X: 40, Y: 30}
+	for i := 0; i < 10; i++ {
+		c.Move(s)
+	}
+
+	img := s.Output()
+	for y := 0; y < p.DestHeight; y++ {
+		for x := 0; x < p.DestWidth; x++ {
+			if r, _, _, _ := img.At(x, y).RGBA(); r != 0xffff {
+				t.Fatalf("crack line drawn at %d, %d inside the mask", x, y)
+			}
+		}
+	}
+}
+
+func TestCrackEdgesSeedAlongEdges(t *testing.T) {
+	// a vertical edge down the middle should seed vertical cracks
+	src := image.NewGray(image.Rect(0, 0, 80, 60))
+	for y := 0; y < 60; y++ {
+		for x := 40; x < 80; x++ {
+			src.SetGray(x, y, color.Gray{255})
+		}
+	}
+
+	p := testCrackParams(80, 60)
+	p.Seeds = 0
+	p.EdgeSource = src
+	p.EdgeDensity = 1
+	s := NewCrackSketch(p)
+
+	if len(s.occupied) == 0 {
+		t.Fatal("expected the edge to seed cracks")
+	}
+	for _, i := range s.occupied {
+		if x := i % p.DestWidth; x < 38 || x > 41 {
+			t.Errorf("seed at x %d is away from the edge", x)
+		}
+		if a := s.Grid[i]; a != 90 && a != 270 {
+			t.Errorf("seed angle %d does not run along the edge", a)
+		}
+	}
+}
+
+func TestCrackEdgeSeedsFormLines(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 80, 60))
+	for y := 0; y < 60; y++ {
+		for x := 40; x < 80; x++ {
+			src.SetGray(x, y, color.Gray{255})
+		}
+	}
+
+	p := testCrackParams(80, 60)
+	p.Seeds = 0
+	p.StartingCracks = 0
+	p.EdgeSource = src
+	p.EdgeDensity = 0.05
+	p.Rand = rand.New(rand.NewSource(1))
+	s := NewCrackSketch(p)
+
+	// a handful of runs is enough to line the whole edge, where single pixels would leave it dotted
+	img := s.Output()
+	lined := false
+	for x := 38; x <= 41; x++ {
+		n := 0
+		for y := 0; y < 60; y++ {
+			if s.Grid[y*80+x] != blankAngle {
+				n++
+				if r, _, _, _ := img.At(x, y).RGBA(); r == 0xffff {
+					t.Errorf("seed at %d, %d isn't drawn", x, y)
+				}
+			}
+		}
+		lined = lined || n == 60
+	}
+	if !lined {
+		t.Error("expected a column of seeds running the length of the edge")
+	}
+}
+
+func TestCrackSandColorFromSource(t *testing.T) {
+	// left half red, right half blue, at half the canvas size
+	src := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			if x < 20 {
+				src.SetRGBA(x, y, color.RGBA{200, 0, 0, 255})
+			} else {
+				src.SetRGBA(x, y, color.RGBA{0, 0, 200, 255})
+			}
+		}
+	}
+
+	p := testCrackParams(80, 60)
+	p.ColorSource = src
+	p.StartingCracks = 10
+	s := NewCrackSketch(p)
+
+	// cracks haven't moved yet, so each is still at the point its color came from
+	for _, c := range s.cracks {
+		want := [3]int{200, 0, 0}
+		if c.X >= 40 {
+			want = [3]int{0, 0, 200}
+		}
+		if got := [3]int{c.SP.R, c.SP.G, c.SP.B}; got != want {
+			t.Errorf("crack started near x %.0f has sand %v, want %v", c.X, got, want)
+		}
+	}
+}
+
+func TestNormalizeAngle(t *testing.T) {
+	for a, want := range map[float64]float64{0: 0, 359: 359, 360: 0, 720.5: 0.5, -90: 270, -360: 0, -0.5: 359.5} {
+		if got := normalizeAngle(a); got != want {
+			t.Errorf("normalizeAngle(%v) = %v, want %v", a, got, want)
+		}
+	}
+}
+
+func TestAngleDiff(t *testing.T) {
+	for _, c := range []struct{ a, b, want float64 }{
+		{10, 20, 10},
+		{1, 359, 2},
+		{359, 1, 2},
+		{0, 360, 0},
+		{-85, 275, 0},
+		{90, 270, 180},
+		{350, 10, 20},
+		{-10, 370, 20},
+	} {
+		if got := angleDiff(c.a, c.b); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("angleDiff(%v, %v) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestAngleTolerance(t *testing.T) {
+	for _, c := range []struct {
+		curve     string
+		curvature float64
+		want      float64
+	}{
+		{"", 2, 5},
+		{"straight", 2, 5},
+		{"constant", 2, 11},
+		{"drift", -2, 11},
+		{"noise", 0.5, 6.5},
+	} {
+		s := &CrackSketch{CrackParams: CrackParams{Curve: c.curve, Curvature: c.curvature}}
+		if got := s.angleTolerance(); got != c.want {
+			t.Errorf("%s %v: got %v, want %v", c.curve, c.curvature, got, c.want)
+		}
+	}
+}
+
+func TestCurvedCracksEndAtOtherCracks(t *testing.T) {
+	for _, curve := range []string{"constant", "drift", "noise"} {
+		p := testCrackParams(60, 40)
+		p.Curve = curve
+		p.Curvature = 0.2
+		p.CrackLimit = 1
+		s := NewCrackSketch(p)
+
+		// a single vertical crack down x 30 is all that's on the grid
+		s.occupied = s.occupied[:0]
+		for i := range s.Grid {
+			s.Grid[i] = blankAngle
+		}
+		for y := 0; y < 40; y++ {
+			s.Grid[y*60+30] = 90
+			s.occupied = append(s.occupied, y*60+30)
+		}
+
+		// heading right at it, the crack must end when it gets there rather than bend along or through it
+		c := crack{X: 5, Y: 20, T: 0, turn: p.Curvature, SP: newsandPainter(s, 5, 20)}
+		ended := false
+		for i := 0; i < 200 && !ended; i++ {
+			prev := c
+			c.Move(s)
+			// a crack that ends starts over from a cell of the wall, anything else is one step on
+			if math.Abs(math.Hypot(c.X-prev.X, c.Y-prev.Y)-p.StepLength) > 1e-6 {
+				ended = true
+				if prev.X < 29 || prev.X > 31 {
+					t.Errorf("%s: crack ended at x %.2f, away from the crack at x 30", curve, prev.X)
+				}
+			}
+		}
+		if !ended {
+			t.Errorf("%s: crack ran into another crack and kept going", curve)
+		}
+	}
+}
+
+// checkOccupied fails unless the occupied list holds every cell with an angle exactly once, and nothing else
+func checkOccupied(t *testing.T, s *CrackSketch) {
+	t.Helper()
+	seen := map[int]bool{}
+	for _, i := range s.occupied {
+		if seen[i] {
+			t.Fatalf("cell %d is in the occupied list twice", i)
+		}
+		seen[i] = true
+		if s.Grid[i] == blankAngle {
+			t.Fatalf("cell %d is in the occupied list but blank", i)
+		}
+	}
+	for i, a := range s.Grid {
+		if a != blankAngle && !seen[i] {
+			t.Fatalf("cell %d holds %d but isn't in the occupied list", i, a)
+		}
+	}
+}
+
+func TestCrackStartsOnOccupiedCells(t *testing.T) {
+	p := testCrackParams(40, 30)
+	p.Seeds = 5
+
+	sparse := NewCrackSketch(p)
+	// nearly full: everything but one column holds an angle
+	full := NewCrackSketch(p)
+	full.occupied = full.occupied[:0]
+	for i := range full.Grid {
+		full.Grid[i] = blankAngle
+		if i%40 != 20 {
+			full.Grid[i] = (i * 7) % 360
+			full.occupied = append(full.occupied, i)
+		}
+	}
+
+	for name, s := range map[string]*CrackSketch{"sparse": sparse, "nearly full": full} {
+		for i := 0; i < 2000; i++ {
+			s.Update()
+		}
+		checkOccupied(t, s)
+
+		var c crack
+		for i := 0; i < 500; i++ {
+			c.findStart(s)
+			if a := s.Grid[int(c.Y)*40+int(c.X)]; a == blankAngle {
+				t.Fatalf("%s: crack started on the blank cell %.0f, %.0f", name, c.X, c.Y)
+			}
+		}
+	}
+}
+
+// blendRecorder is a canvas that only counts the pixels blended into it
+type blendRecorder struct {
+	blend.Surface
+	blends int
+}
+
+func (r *blendRecorder) Blend(x, y int, c [3]int, alpha float64, mode blend.Mode) {
+	r.blends++
+}
+
+func TestSandPainterSkipsASingleGrain(t *testing.T) {
+	p := testCrackParams(20, 20)
+	p.HDR = true
+	s := NewCrackSketch(p)
+	canvas := &blendRecorder{Surface: s.canvas}
+	s.canvas = canvas
+
+	// a region a pixel and a half across has one grain, which has no spacing to place it by
+	sp := &sandPainter{R: 10, G: 20, B: 30, GrainSize: 0.5}
+	sp.render(s, 11.5, 10, 10, 10)
+	if canvas.blends != 0 {
+		t.Errorf("got %d blends, want none", canvas.blends)
+	}
+}
@@ -1,14 +1,15 @@
 package sketch
 
 import (
+	"errors"
 	"fmt"
 	"image"
-	"image/color"
+	"image/draw"
 	"math"
-	"math/rand"
 
 	"github.com/fogleman/gg"
 	"github.com/teacat/noire"
+	"gitlab.com/ericworkman/generative/blend"
 	"gitlab.com/ericworkman/generative/util"
 )
 
@@ -20,21 +21,122 @@ type CrawlParams struct {
 	Iterations int
 	Count      int
 	Start      string
+	// Collision is what a crawler does when it would cross another trail: none, avoid or stop
+	Collision string
+	// BranchChance is the chance each step that a crawler splits off a child with its heading and color
+	BranchChance float64
+	// MaxCrawlers caps the number of crawlers once they start branching
+	MaxCrawlers int
+	// Points are the starting locations of the points start, crawlers take them in turn
+	Points [][2]float64
+	// Edge is what a crawler does at the edge of the canvas: stop, wrap or reflect
+	Edge string
+	// Field steers crawlers along a vector field: none, noise, curl or image
+	Field string
+	// FieldScale is the size of features in the noise and curl fields, in pixels
+	FieldScale float64
+	// FieldStrength is how closely crawlers follow the field, from 0 (not at all) to 1 (exactly)
+	FieldStrength float64
+	// FieldSource is the image whose luminance gradient makes the image field
+	FieldSource image.Image `json:"-"`
+	// Blend is how the faint background lines combine, one of blend.Modes
+	Blend string
+	Random
+}
+
+// CrawlFields are the accepted values of CrawlParams.Field
+var CrawlFields = []string{"none", "noise", "curl", "image"}
+
+// CrawlStarts are the accepted values of CrawlParams.Start
+var CrawlStarts = []string{"center", "corner", "random", "edge", "ring", "points"}
+
+// CrawlEdges are the accepted values of CrawlParams.Edge
+var CrawlEdges = []string{"stop", "wrap", "reflect"}
+
+// CrawlCollisions are the accepted values of CrawlParams.Collision
+var CrawlCollisions = []string{"none", "avoid", "stop"}
+
+// Validate rejects unknown starts, edges, fields, collisions and blend modes, counts over one per pixel, points off the
+// canvas, and modes missing what they need
+func (p CrawlParams) Validate() error {
+	if err := checkCount("count", p.Count, p.DestWidth, p.DestHeight); err != nil {
+		return err
+	}
+	if err := checkCount("max crawlers", p.MaxCrawlers, p.DestWidth, p.DestHeight); err != nil {
+		return err
+	}
+	if err := checkChoice("start", p.Start, CrawlStarts); err != nil {
+		return err
+	}
+	if p.Start == "points" && len(p.Points) == 0 {
+		return errors.New("the points start needs at least one point")
+	}
+	for _, pt := range p.Points {
+		// written as not inside so a NaN coordinate is rejected too
+		if !(pt[0] >= 0 && pt[0] < float64(p.DestWidth) && pt[1] >= 0 && pt[1] < float64(p.DestHeight)) {
+			return fmt.Errorf("point %v,%v is off the %dx%d canvas", pt[0], pt[1], p.DestWidth, p.DestHeight)
+		}
+	}
+	if err := checkOptionalChoice("edge", p.Edge, CrawlEdges); err != nil {
+		return err
+	}
+	if err := checkOptionalChoice("field", p.Field, CrawlFields); err != nil {
+		return err
+	}
+	if (p.Field == "noise" || p.Field == "curl") && p.FieldScale <= 0 {
+		return errors.New("field scale must be positive")
+	}
+	if p.Field == "image" && p.FieldSource == nil {
+		return errors.New("the image field needs a source image")
+	}
+	if p.FieldStrength < 0 || p.FieldStrength > 1 {
+		return errors.New("field strength must be between 0 and 1")
+	}
+	if err := checkOptionalChoice("collision", p.Collision, CrawlCollisions); err != nil {
+		return err
+	}
+	if p.BranchChance < 0 || p.BranchChance > 1 {
+		return errors.New("branch chance must be between 0 and 1")
+	}
+	if _, err := blend.ParseMode(p.Blend); err != nil {
+		return err
+	}
+	return nil
 }
 
 // CrawlSketch wraps all the components needed to draw the sketch
 type CrawlSketch struct {
 	CrawlParams
-	DC       *gg.Context
-	crawlers []crawler
+	// background is the white canvas the faint lines blend into, and foreground a transparent layer for the trails
+	// crawlers draw into both as they move, and Output puts the foreground over the background
+	background *blend.Buffer
+	foreground *gg.Context
+	mode       blend.Mode
+	crawlers   []crawler
+	// occupied holds the id of the crawler whose trail covers each pixel, 0 for none, nil when not colliding
+	occupied []int32
+	noise    *util.Noise
+	// luminance of the field source at every pixel, for the image field
+	fieldLum []float64
 }
 
+// crawlStep is the distance a crawler moves each step
+const crawlStep = 5.0
+
+// avoidAttempts is the number of headings an avoiding crawler tries before it gives up
+const avoidAttempts = 8
+
 type point struct {
 	x float64
 	y float64
+	// jump is true when the crawler wrapped around to reach this point, so no line leads to it
+	jump bool
 }
 
 type crawler struct {
+	id         int32
+	parent     int32
+	stopped    bool
 	start      point
 	current    point
 	history    []point
@@ -46,105 +148,301 @@ type crawler struct {
 }
 
 func (c *crawler) crawl(s *CrawlSketch) {
+	if c.stopped {
+		return
+	}
 	if c.current.x >= 0 && c.current.x < float64(s.DestWidth) && c.current.y >= 0 && c.current.y < float64(s.DestHeight) {
-		awayAngle := c.theta + util.RandFloat64Range(c.thetaRange)
+		current := c.step(s)
 
-		xx1 := c.r * math.Cos(awayAngle)
-		yy1 := c.r * math.Sin(awayAngle)
+		if s.occupied != nil && s.blocked(c, c.current, current) {
+			if s.Collision == "stop" {
+				c.stopped = true
+				return
+			}
+			// avoid by trying other headings, and stop when hemmed in
+			free := false
+			for k := 0; k < avoidAttempts && !free; k++ {
+				current = c.step(s)
+				free = !s.blocked(c, c.current, current)
+			}
+			if !free {
+				c.stopped = true
+				return
+			}
+		}
 
-		current := point{c.current.x + xx1, c.current.y + yy1}
+		if s.occupied != nil {
+			s.mark(c, c.current, current)
+		}
+		switch s.Edge {
+		case "wrap":
+			current = s.wrap(current)
+		case "reflect":
+			current = c.reflect(s, current)
+		}
 		c.current = current
 		c.history = append(c.history, current)
 	}
 }
 
-func (s *CrawlSketch) addCrawler(start string) {
+// wrap moves a point that left the canvas to the opposite side
+func (s *CrawlSketch) wrap(p point) point {
+	w, h := float64(s.DestWidth), float64(s.DestHeight)
+	if p.x < 0 || p.x >= w || p.y < 0 || p.y >= h {
+		p.x = math.Mod(math.Mod(p.x, w)+w, w)
+		p.y = math.Mod(math.Mod(p.y, h)+h, h)
+		p.jump = true
+	}
+	return p
+}
+
+// reflect bounces a point that left the canvas back in, and turns the crawler's heading away from the edge
+func (c *crawler) reflect(s *CrawlSketch, p point) point {
+	w, h := float64(s.DestWidth), float64(s.DestHeight)
+	if p.x < 0 || p.x >= w {
+		if p.x < 0 {
+			p.x = -p.x
+		} else {
+			p.x = 2*w - p.x - 1
+		}
+		c.theta = math.Pi - c.theta
+	}
+	if p.y < 0 || p.y >= h {
+		if p.y < 0 {
+			p.y = -p.y
+		} else {
+			p.y = 2*h - p.y - 1
+		}
+		c.theta = -c.theta
+	}
+	return p
+}
+
+// step picks the next point, some way off the crawler's heading
+// A field turns the heading towards the field's direction and narrows the spread by the field strength.
+func (c *crawler) step(s *CrawlSketch) point {
+	theta := c.theta
+	thetaRange := c.thetaRange
+	if a, ok := s.fieldAngle(c.current); ok {
+		theta += s.FieldStrength * math.Remainder(a-theta, 2*math.Pi)
+		thetaRange *= 1 - s.FieldStrength
+	}
+	awayAngle := theta + util.RandFloat64Range(s.Rand, thetaRange)
+
+	xx1 := c.r * math.Cos(awayAngle)
+	yy1 := c.r * math.Sin(awayAngle)
+
+	return point{x: c.current.x + xx1, y: c.current.y + yy1}
+}
+
+// fieldAngle is the direction of the sketch's field at a point, ok is false when there's no field or it has no direction there
+func (s *CrawlSketch) fieldAngle(p point) (float64, bool) {
+	switch s.Field {
+	case "noise":
+		return 2 * math.Pi * s.noise.At(p.x/s.FieldScale, p.y/s.FieldScale), true
+	case "curl":
+		// the curl of a noise potential flows without sources or sinks, so lines swirl rather than bunch up
+		const eps = 0.5
+		x, y := p.x/s.FieldScale, p.y/s.FieldScale
+		dx := (s.noise.At(x+eps/s.FieldScale, y) - s.noise.At(x-eps/s.FieldScale, y)) / (2 * eps)
+		dy := (s.noise.At(x, y+eps/s.FieldScale) - s.noise.At(x, y-eps/s.FieldScale)) / (2 * eps)
+		return math.Atan2(-dx, dy), dx != 0 || dy != 0
+	case "image":
+		x, y := int(p.x), int(p.y)
+		if x < 0 || y < 0 || x >= s.DestWidth || y >= s.DestHeight {
+			return 0, false
+		}
+		gx, gy := sobel(s.fieldLum, s.DestWidth, s.DestHeight, x, y)
+		return math.Atan2(gy, gx), math.Hypot(gx, gy) > 1e-3
+	}
+	return 0, false
+}
+
+// walk calls f for points every half pixel along a segment, skipping the first pixel which the crawler already sits on
+func walk(from, to point, f func(x, y int) bool) {
+	d := math.Hypot(to.x-from.x, to.y-from.y)
+	for t := 1.0; t <= d; t += 0.5 {
+		if !f(int(from.x+(to.x-from.x)*t/d), int(from.y+(to.y-from.y)*t/d)) {
+			return
+		}
+	}
+}
+
+// blocked reports whether a segment crosses the trail of any crawler other than c or the one it branched from
+func (s *CrawlSketch) blocked(c *crawler, from, to point) bool {
+	hit := false
+	walk(from, to, func(x, y int) bool {
+		if x < 0 || y < 0 || x >= s.DestWidth || y >= s.DestHeight {
+			return true
+		}
+		o := s.occupied[y*s.DestWidth+x]
+		hit = o != 0 && o != c.id && o != c.parent
+		return !hit
+	})
+	return hit
+}
+
+// mark records a segment of c's trail on the occupancy grid
+func (s *CrawlSketch) mark(c *crawler, from, to point) {
+	walk(from, to, func(x, y int) bool {
+		if x >= 0 && y >= 0 && x < s.DestWidth && y < s.DestHeight && s.occupied[y*s.DestWidth+x] == 0 {
+			s.occupied[y*s.DestWidth+x] = c.id
+		}
+		return true
+	})
+}
+
+// branch starts a child crawler at c's current point, with c's heading and colors
+func (s *CrawlSketch) branch(c *crawler) {
+	child := crawler{
+		id:         int32(len(s.crawlers) + 1),
+		parent:     c.id,
+		start:      c.current,
+		current:    c.current,
+		history:    []point{c.current},
+		theta:      c.theta,
+		thetaRange: c.thetaRange,
+		r:          c.r,
+		c:          c.c,
+		light:      c.light,
+	}
+	s.crawlers = append(s.crawlers, child)
+}
+
+func (s *CrawlSketch) addCrawler(i int) {
 	x := float64(s.DestWidth / 2)
 	y := float64(s.DestHeight / 2)
-	theta := util.RandFloat64RangeFrom(0, 2*math.Pi)
+	theta := util.RandFloat64RangeFrom(s.Rand, 0, 2*math.Pi)
 	thetaRange := 2 * math.Pi / 3
-	if start == "corner" {
+	switch s.Start {
+	case "corner":
 		x = 10.0
 		y = 10.0
-		theta = util.RandFloat64RangeFrom(0.05, math.Pi/2.05)
+		theta = util.RandFloat64RangeFrom(s.Rand, 0.05, math.Pi/2.05)
+		thetaRange = math.Pi / 2
+	case "random":
+		x = s.Rand.Float64() * float64(s.DestWidth)
+		y = s.Rand.Float64() * float64(s.DestHeight)
+	case "edge":
+		// a random point on the border, heading roughly inwards
+		x, y, theta = s.edgeStart()
 		thetaRange = math.Pi / 2
+	case "ring":
+		// evenly spaced around a circle, heading outwards
+		a := 2 * math.Pi * float64(i) / float64(s.Count)
+		ringR := float64(util.MinInt(s.DestWidth, s.DestHeight)) / 3
+		x += ringR * math.Cos(a)
+		y += ringR * math.Sin(a)
+		theta = a
+		thetaRange = math.Pi / 2
+	case "points":
+		p := s.Points[i%len(s.Points)]
+		x, y = p[0], p[1]
 	}
 
-	r := 5.0
+	r := crawlStep
 
 	xx := x + r*math.Cos(theta)
 	yy := y + r*math.Sin(theta)
 
-	c := noire.NewRGBA(rand.Float64()*128, rand.Float64()*128, 128+rand.Float64()*127, 1)
+	c := noire.NewRGBA(s.Rand.Float64()*128, s.Rand.Float64()*128, 128+s.Rand.Float64()*127, 1)
 	lightc := c.Lighten(.35)
 
-	crawly := crawler{start: point{xx, yy}, current: point{xx, yy}, theta: theta, thetaRange: thetaRange, r: r, history: []point{{x: xx, y: yy}}, c: c, light: lightc}
+	crawly := crawler{id: int32(len(s.crawlers) + 1), start: point{x: xx, y: yy}, current: point{x: xx, y: yy}, theta: theta, thetaRange: thetaRange, r: r, history: []point{{x: xx, y: yy}}, c: c, light: lightc}
 	s.crawlers = append(s.crawlers, crawly)
 }
 
+// edgeStart picks a point on the canvas border and a heading pointing into the canvas
+// Points stay a step away from the corners, where the first step could otherwise land off the canvas.
+func (s *CrawlSketch) edgeStart() (x, y, theta float64) {
+	w, h := float64(s.DestWidth), float64(s.DestHeight)
+	along := func(length float64) float64 {
+		return crawlStep + s.Rand.Float64()*(length-2*crawlStep)
+	}
+	jitter := util.RandFloat64Range(s.Rand, math.Pi/4)
+	switch s.Rand.Intn(4) {
+	case 0:
+		return along(w), 0, math.Pi/2 + jitter
+	case 1:
+		return w - 1, along(h), math.Pi + jitter
+	case 2:
+		return along(w), h - 1, -math.Pi/2 + jitter
+	default:
+		return 0, along(h), jitter
+	}
+}
+
 // NewCrawlSketch initializes the canvas and CrawlSketch
 func NewCrawlSketch(params CrawlParams) *CrawlSketch {
 	fmt.Println("Starting Sketch")
 
 	s := &CrawlSketch{CrawlParams: params}
+	s.Rand = randSource(s.Rand)
 
-	// canvas is a gg image context and contains what gets drawn to the screen
-	canvas := gg.NewContext(s.DestWidth, s.DestHeight)
-	canvas.SetLineWidth(0.0)
-	canvas.SetColor(color.White)
-	canvas.DrawRectangle(0, 0, float64(s.DestWidth), float64(s.DestHeight))
-	canvas.FillPreserve()
-	canvas.Stroke()
-	s.DC = canvas
+	s.background = blend.NewBuffer(s.DestWidth, s.DestHeight, [3]int{255, 255, 255})
+	s.mode, _ = blend.ParseMode(s.Blend)
+	s.foreground = gg.NewContext(s.DestWidth, s.DestHeight)
+	s.foreground.SetLineWidth(1.0)
+
+	if s.Collision == "avoid" || s.Collision == "stop" {
+		s.occupied = make([]int32, s.DestWidth*s.DestHeight)
+	}
 
-	s.DC.SetLineWidth(1.0)
+	switch s.Field {
+	case "noise", "curl":
+		s.noise = util.NewNoise(s.Rand)
+	case "image":
+		s.fieldLum = luminanceGrid(s.FieldSource, s.DestWidth, s.DestHeight)
+	}
 
 	for i := 0; i < s.Count; i++ {
-		s.addCrawler(s.Start)
+		s.addCrawler(i)
 	}
 
 	return s
 }
 
 // Output produces an image output of the current state of the sketch
+// Each call composes the layers into a new image, so an image already returned doesn't change as the sketch goes on.
 func (s *CrawlSketch) Output() image.Image {
-	//draw all background lines, then all foreground lines
-	for j := 0; j < len(s.crawlers); j++ {
-		crawly := s.crawlers[j]
-		r, g, b := crawly.light.RGB()
-		s.DC.SetRGBA255(int(r), int(g), int(b), 15)
-		for k := 0; k < len(crawly.history); k++ {
-			p := crawly.history[k]
-			s.DC.DrawLine(crawly.start.x, crawly.start.y, p.x, p.y)
-			s.DC.Stroke()
-		}
-	}
+	img := image.NewRGBA(image.Rect(0, 0, s.DestWidth, s.DestHeight))
+	draw.Draw(img, img.Bounds(), s.background.Image(), image.Point{}, draw.Src)
+	draw.Draw(img, img.Bounds(), s.foreground.Image(), image.Point{}, draw.Over)
+	return img
+}
 
-	for j := 0; j < len(s.crawlers); j++ {
-		crawly := s.crawlers[j]
-		prevX := crawly.start.x
-		prevY := crawly.start.y
-		r, g, b := crawly.c.RGB()
-		s.DC.SetRGBA255(int(r), int(g), int(b), 255)
-		for k := 0; k < len(crawly.history); k++ {
-			p := crawly.history[k]
-			s.DC.DrawLine(prevX, prevY, p.x, p.y)
-			s.DC.Stroke()
-			prevX = p.x
-			prevY = p.y
-		}
-	}
+// drawStep draws the latest step of a crawler into the layers
+// The background gets a faint line from the crawler's start, the foreground the step itself unless the crawler wrapped.
+func (s *CrawlSketch) drawStep(c *crawler) {
+	p := c.history[len(c.history)-1]
+	prev := c.history[len(c.history)-2]
 
-	return s.DC.Image()
+	r, g, b := c.light.RGB()
+	blend.Line(s.background, c.start.x, c.start.y, p.x, p.y, [3]int{int(r), int(g), int(b)}, 15.0/255, s.mode)
+
+	if !p.jump {
+		r, g, b = c.c.RGB()
+		s.foreground.SetRGBA255(int(r), int(g), int(b), 255)
+		s.foreground.DrawLine(prev.x, prev.y, p.x, p.y)
+		s.foreground.Stroke()
+	}
 }
 
 // Update makes a logical step into generation
 func (s *CrawlSketch) Update(i int) {
-
-	for j := 0; j < len(s.crawlers); j++ {
+	// children branched off this step start moving next step
+	n := len(s.crawlers)
+	for j := 0; j < n; j++ {
 		crawly := s.crawlers[j]
+		steps := len(crawly.history)
 		crawly.crawl(s)
+		if len(crawly.history) > steps {
+			s.drawStep(&crawly)
+		}
 		s.crawlers[j] = crawly
-	}
 
+		if s.BranchChance > 0 && !crawly.stopped && len(s.crawlers) < s.MaxCrawlers && s.Rand.Float64() < s.BranchChance {
+			s.branch(&crawly)
+		}
+	}
 }
@@ -0,0 +1,97 @@
+package sketch
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"testing"
+)
+
+func TestCrawlBranchingRespectsMax(t *testing.T) {
+	s := NewCrawlSketch(CrawlParams{DestWidth: 200, DestHeight: 150, Count: 2, Start: "center", Collision: "avoid", BranchChance: 0.5, MaxCrawlers: 12})
+	for i := 1; i <= 100; i++ {
+		s.Update(i)
+	}
+	if len(s.crawlers) != 12 {
+		t.Errorf("got %d crawlers, want the maximum of 12", len(s.crawlers))
+	}
+	for _, c := range s.crawlers[2:] {
+		if c.parent == 0 {
+			t.Error("branched crawler has no parent")
+		}
+	}
+}
+
+func TestCrawlEdgesKeepCrawlersOnCanvas(t *testing.T) {
+	for _, edge := range []string{"wrap", "reflect"} {
+		s := NewCrawlSketch(CrawlParams{DestWidth: 60, DestHeight: 40, Count: 5, Start: "edge", Edge: edge})
+		for i := 1; i <= 300; i++ {
+			s.Update(i)
+		}
+		for _, c := range s.crawlers {
+			if c.current.x < 0 || c.current.x >= 60 || c.current.y < 0 || c.current.y >= 40 {
+				t.Errorf("%s: crawler left the canvas at %.1f, %.1f", edge, c.current.x, c.current.y)
+			}
+			if len(c.history) != 301 {
+				t.Errorf("%s: crawler stopped after %d steps", edge, len(c.history)-1)
+			}
+		}
+	}
+}
+
+func TestCrawlFollowsFieldAtFullStrength(t *testing.T) {
+	for _, field := range []string{"noise", "curl"} {
+		s := NewCrawlSketch(CrawlParams{DestWidth: 300, DestHeight: 200, Count: 4, Start: "random", Edge: "wrap", Field: field, FieldScale: 50, FieldStrength: 1})
+		for i := 1; i <= 50; i++ {
+			s.Update(i)
+		}
+		for _, c := range s.crawlers {
+			for k := 1; k < len(c.history); k++ {
+				from, to := c.history[k-1], c.history[k]
+				if to.jump {
+					continue
+				}
+				want, _ := s.fieldAngle(from)
+				got := math.Atan2(to.y-from.y, to.x-from.x)
+				if math.Abs(math.Remainder(got-want, 2*math.Pi)) > 1e-6 {
+					t.Fatalf("%s: step heads %.3f, field points %.3f", field, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestCrawlOutputIsRepeatable(t *testing.T) {
+	s := NewCrawlSketch(CrawlParams{DestWidth: 80, DestHeight: 60, Count: 3, Start: "center"})
+	for i := 1; i <= 20; i++ {
+		s.Update(i)
+		if i == 10 {
+			s.Output()
+		}
+	}
+	first := append([]uint8(nil), s.Output().(*image.RGBA).Pix...)
+	for k := 0; k < 3; k++ {
+		if !bytes.Equal(first, s.Output().(*image.RGBA).Pix) {
+			t.Fatalf("output changed on call %d", k+2)
+		}
+	}
+}
+
+func TestCrawlOutputKeepsEarlierImages(t *testing.T) {
+	s := NewCrawlSketch(CrawlParams{DestWidth: 80, DestHeight: 60, Count: 3, Start: "center"})
+	for i := 1; i <= 10; i++ {
+		s.Update(i)
+	}
+	early := s.Output().(*image.RGBA)
+	saved := append([]uint8(nil), early.Pix...)
+	for i := 11; i <= 40; i++ {
+		s.Update(i)
+	}
+	late := s.Output().(*image.RGBA)
+	if !bytes.Equal(saved, early.Pix) {
+		t.Error("an image returned by Output changed as the sketch went on")
+	}
+	if bytes.Equal(saved, late.Pix) {
+		t.Error("expected the sketch to have moved on between the two outputs")
+	}
+}
@@ -1,91 +1,212 @@
 package sketch
 
 import (
+	"errors"
 	"fmt"
 	"image"
-	"image/color"
-	"math/rand"
+	"math"
 
-	"github.com/fogleman/gg"
+	"github.com/teacat/noire"
+	"gitlab.com/ericworkman/generative/blend"
 	"gitlab.com/ericworkman/generative/util"
 )
 
+// sparkColor is the warm white of a rising shell
+var sparkColor = [3]int{253, 255, 240}
+
 // FireworkParams contains externally-provided parameters
 type FireworkParams struct {
 	// tweakable parameters for the cli
 	DestWidth  int
 	DestHeight int
 	Iterations int
+	// Shells is the number of shells launched over the sketch
+	Shells int
+	// Particles is the number of particles each shell bursts into
+	Particles int
+	// BurstSpeed is the fastest a particle leaves a burst, in pixels per iteration
+	BurstSpeed float64
+	// Gravity pulls shells and particles down, in pixels per iteration per iteration
+	Gravity float64
+	// Drag is the fraction of a particle's speed lost each iteration
+	Drag float64
+	// Life is the average number of iterations a particle glows for
+	Life int
+	// Palette replaces the random shell colors when it is not empty
+	Palette [][3]int
+	// Blend is how sparks combine with the sky, one of blend.Modes
+	Blend string
+	HDROptions
+	Random
+}
+
+// Validate rejects negative counts, more particles than pixels, drag outside 0 to 1, an unknown blend mode, and gravity,
+// life or hdr gamma that isn't positive
+func (p FireworkParams) Validate() error {
+	if p.Shells < 0 || p.Particles < 0 {
+		return errors.New("shells and particles can't be negative")
+	}
+	// every particle of every shell is held at once, so it's their product that's limited
+	if p.Particles > 0 && p.Shells > p.DestWidth*p.DestHeight/p.Particles {
+		return fmt.Errorf("shells times particles must be at most %d, one for each pixel", p.DestWidth*p.DestHeight)
+	}
+	if p.Gravity <= 0 {
+		return errors.New("gravity must be positive")
+	}
+	if p.Drag < 0 || p.Drag >= 1 {
+		return errors.New("drag must be between 0 and 1")
+	}
+	if p.Life < 1 {
+		return errors.New("life must be at least 1")
+	}
+	if _, err := blend.ParseMode(p.Blend); err != nil {
+		return err
+	}
+	return p.HDROptions.Validate()
 }
 
 // FireworkSketch wraps all the components needed to draw the firework sketch
 type FireworkSketch struct {
 	FireworkParams
-	DC    *gg.Context
-	slope float64
-	x1    int
+	canvas    blend.Surface
+	mode      blend.Mode
+	slope     float64
+	x1        int
+	shells    []shell
+	particles []particle
+}
+
+// shell rises from the ground and bursts at the top of its flight
+type shell struct {
+	launch int
+	x, y   float64
+	vx, vy float64
+	color  [3]int
+	burst  bool
+}
+
+type particle struct {
+	x, y   float64
+	vx, vy float64
+	age    int
+	life   int
+	color  [3]int
 }
 
 // NewFireworkSketch initializes the canvas and FireworkSketch
+// Shells launch from the bottom edge at times spread so they burst in time to fade out before the end, aimed to burst below
+// a line from some middle point on the left to the inverse point on the right. Sparks are usually drawn additively,
+// so overlapping sparks brighten towards white.
 func NewFireworkSketch(params FireworkParams) *FireworkSketch {
 	fmt.Println("Starting Sketch")
 
 	s := &FireworkSketch{FireworkParams: params}
+	s.Rand = randSource(s.Rand)
 
 	// Draw a line from some middle point on the left to the inverse point on the right
-	// all bursts will be below this line, save for the offsets
-	startX := int(util.RandFloat64RangeFrom(0.33*float64(params.DestHeight), 0.67*float64(params.DestHeight)))
+	// all bursts will be below this line
+	startX := int(util.RandFloat64RangeFrom(s.Rand, 0.33*float64(params.DestHeight), 0.67*float64(params.DestHeight)))
 	s.slope = float64(s.DestHeight-2*startX) / float64(s.DestWidth)
 	s.x1 = startX
-	//fmt.Println("y = (", s.slope, ") * x + ", s.x1)
-
-	// canvas is a gg image context and contains what gets drawn to the screen
-	canvas := gg.NewContext(s.DestWidth, s.DestHeight)
-	canvas.SetLineWidth(0.0)
-	canvas.SetColor(color.Black)
-	canvas.DrawRectangle(0, 0, float64(s.DestWidth), float64(s.DestHeight))
-	canvas.FillPreserve()
-	canvas.Stroke()
-	s.DC = canvas
+
+	// canvas contains what gets drawn to the screen
+	s.canvas = newSurface(s.DestWidth, s.DestHeight, [3]int{0, 0, 0}, s.HDROptions)
+	s.mode, _ = blend.ParseMode(s.Blend)
+
+	for k := 0; k < s.Shells; k++ {
+		s.shells = append(s.shells, s.newShell())
+	}
 	return s
 }
 
+// newShell picks a burst point below the line and the launch that reaches it
+func (s *FireworkSketch) newShell() shell {
+	rndX := s.Rand.Float64() * float64(s.DestWidth)
+	rndY := util.RandFloat64RangeFrom(s.Rand, s.slope*rndX+float64(s.x1), float64(s.DestHeight))
+
+	// rising to a height h against gravity g takes a speed of sqrt(2gh) and h/g of that many iterations
+	ground := float64(s.DestHeight)
+	vy := -math.Sqrt(2 * s.Gravity * (ground - rndY))
+	flight := math.Max(-vy/s.Gravity, 1)
+	x := rndX + util.RandFloat64Range(s.Rand, float64(s.DestWidth)/20)
+
+	var c [3]int
+	if len(s.Palette) > 0 {
+		c = s.Palette[s.Rand.Intn(len(s.Palette))]
+	} else {
+		r, g, b := noire.NewHSL(s.Rand.Float64()*360, 80, 60).RGB()
+		c = [3]int{int(r), int(g), int(b)}
+	}
+
+	return shell{
+		launch: s.Rand.Intn(util.MaxInt(s.Iterations-int(flight)-s.Life/3, 1)),
+		x:      x,
+		y:      ground,
+		vx:     (rndX - x) / flight,
+		vy:     vy,
+		color:  c,
+	}
+}
+
 // Output produces an image output of the current state of the sketch
 func (s *FireworkSketch) Output() image.Image {
-	return s.DC.Image()
+	return s.canvas.Image()
 }
 
 // Update makes a logical step into generation
 func (s *FireworkSketch) Update(i int) {
-	rndX := rand.Float64() * float64(s.DestWidth)
-	rndY := util.RandFloat64RangeFrom(s.slope*rndX+float64(s.x1), float64(s.DestHeight))
-
-	// burst
-	color := [3]int{253, 255, 240}
-	offsets := [...][2]float64{
-		{0, 0},
-		{-0.75, -0.5},
-		{-0.75, 0.5},
-		{0.75, 0.5},
-		{0.75, -0.5},
-
-		{-0.25, 1.5},
-		{0.25, 1.5},
-
-		{0, 2},
-		{0, 3},
-		{0, 5},
-		{0, 8},
+	for k := range s.shells {
+		sh := &s.shells[k]
+		if sh.burst || i < sh.launch {
+			continue
+		}
+		x, y := sh.x, sh.y
+		sh.x += sh.vx
+		sh.y += sh.vy
+		sh.vy += s.Gravity
+		blend.Line(s.canvas, x, y, sh.x, sh.y, sparkColor, 0.7, s.mode)
+		// burst at the top of the flight
+		if sh.vy >= 0 {
+			sh.burst = true
+			s.burst(sh)
+		}
+	}
+
+	alive := s.particles[:0]
+	for _, p := range s.particles {
+		x, y := p.x, p.y
+		p.vx *= 1 - s.Drag
+		p.vy = p.vy*(1-s.Drag) + s.Gravity
+		p.x += p.vx
+		p.y += p.vy
+		p.age++
+		// fade out quickly at first then linger as embers
+		fade := 1 - float64(p.age)/float64(p.life)
+		blend.Line(s.canvas, x, y, p.x, p.y, p.color, 2*fade*fade, s.mode)
+		if p.age < p.life && p.y < float64(s.DestHeight) {
+			alive = append(alive, p)
+		}
 	}
-	scale := 255 * i / s.Iterations
-	alpha := util.MaxInt(10, scale-50)
-	radius := util.MaxFloat64(7, float64(60-3*scale/4))
-	//fmt.Println(scale, alpha, radius)
-
-	for _, offset := range offsets {
-		s.DC.SetRGBA255(color[0], color[1], color[2], alpha)
-		s.DC.DrawCircle(rndX+offset[0]*radius, rndY+offset[1]*radius, radius)
-		s.DC.FillPreserve()
-		s.DC.Stroke()
+	s.particles = alive
+}
+
+// burst spreads a shell's particles out from its position in every direction
+func (s *FireworkSketch) burst(sh *shell) {
+	for k := 0; k < s.Particles; k++ {
+		a := s.Rand.Float64() * 2 * math.Pi
+		// the square root spreads speeds out so the burst fills in rather than forming a ring
+		speed := s.BurstSpeed * math.Sqrt(util.RandFloat64RangeFrom(s.Rand, 0.1, 1))
+		c := sh.color
+		for j := range c {
+			c[j] = util.MinInt(255, util.MaxInt(0, c[j]+util.RandRange(s.Rand, 20)))
+		}
+		s.particles = append(s.particles, particle{
+			x:     sh.x,
+			y:     sh.y,
+			vx:    speed*math.Cos(a) + sh.vx,
+			vy:    speed * math.Sin(a),
+			life:  util.MaxInt(1, s.Life+util.RandRange(s.Rand, s.Life/3)),
+			color: c,
+		})
 	}
 }
@@ -0,0 +1,20 @@
+package sketch
+
+import "testing"
+
+func TestFireworkShellsBurstBeforeTheEnd(t *testing.T) {
+	p := FireworkParams{DestWidth: 160, DestHeight: 90, Iterations: 100, Shells: 6, Particles: 20, BurstSpeed: 90.0 / 80, Gravity: 90.0 / 2000, Drag: 0.05, Life: 30}
+	s := NewFireworkSketch(p)
+	for i := 0; i <= p.Iterations; i++ {
+		s.Update(i)
+	}
+	for k, sh := range s.shells {
+		if !sh.burst {
+			t.Errorf("shell %d never burst", k)
+		}
+		// bursts stay below the line across the canvas
+		if line := s.slope*sh.x + float64(s.x1); sh.y < line-1 {
+			t.Errorf("shell %d burst at %.1f, above the line at %.1f", k, sh.y, line)
+		}
+	}
+}
@@ -1,31 +1,79 @@
 package sketch
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
 	"math"
-	"math/rand"
 
-	"github.com/fogleman/gg"
+	"github.com/teacat/noire"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
 )
 
+// FlipTilings are the ways the canvas can be cut into tiles
+var FlipTilings = []string{"diamond", "square", "hex", "triangle", "penrose", "voronoi"}
+
 // FlipParams contains user input
 type FlipParams struct {
 	DestWidth  int
 	DestHeight int
+	// Tiling is the shape of the tiles, one of FlipTilings
+	Tiling string
+	// MirrorChance is the chance a tile is mirrored left to right
+	MirrorChance float64
+	// RotateChance is the chance a tile is turned half way round
+	RotateChance float64
+	// RotateQuarter turns rotated tiles by a random multiple of 90 degrees instead of 180
+	RotateQuarter bool
+	// ScaleChance is the chance a tile is zoomed by Scale about its center
+	ScaleChance float64
+	Scale       float64
+	// HueChance is the chance a tile's colors are turned HueShift degrees around the color wheel
+	HueChance float64
+	HueShift  float64
+	// DesaturateChance is the chance a tile is drawn in gray
+	DesaturateChance float64
+	// BorderChance is the chance a tile gets a white border
+	BorderChance float64
+	// Margin is how many tile sizes in from the edge of the canvas a tile's center must be before it can be changed
+	// The original layout left about 2 tiles alone at the top, 4 at the left and fewer at the bottom and right, so a
+	// margin of 1 changes more tiles near the edges than it did.
+	Margin float64
+	SourceOptions
+	Random
+}
+
+// Validate rejects unknown tilings, chances outside 0 to 1, and a scale or margin the tiles can't use
+func (p FlipParams) Validate() error {
+	if err := checkChoice("tiling", p.Tiling, FlipTilings); err != nil {
+		return err
+	}
+	for _, c := range []float64{p.MirrorChance, p.RotateChance, p.ScaleChance, p.HueChance, p.DesaturateChance, p.BorderChance} {
+		if c < 0 || c > 1 {
+			return errors.New("chances must be between 0 and 1")
+		}
+	}
+	if p.ScaleChance > 0 && p.Scale <= 0 {
+		return errors.New("scale must be positive")
+	}
+	if p.Margin < 0 {
+		return errors.New("margin can't be negative")
+	}
+	return p.SourceOptions.Validate()
 }
 
+// flipBorder is how far a tile's border reaches in from its edges
+const flipBorder = 5.0
+
 // FlipSketch is the canvas and grid wrapper
 type FlipSketch struct {
 	FlipParams
-	source       image.Image
-	DC           *gg.Context
-	sourceWidth  int
-	sourceHeight int
-	xOffset      float64
-	yOffset      float64
+	// source is prepared to the canvas size, so a tile is painted from the same pixels it covers
+	source *image.RGBA
+	canvas *image.RGBA
 }
 
 // NewFlipSketch creates a stack sketch
@@ -33,102 +81,117 @@ func NewFlipSketch(source image.Image, params FlipParams) *FlipSketch {
 	fmt.Println("Starting Sketch")
 
 	s := &FlipSketch{FlipParams: params}
-	bounds := source.Bounds()
-	s.sourceWidth, s.sourceHeight = bounds.Max.X, bounds.Max.Y
-	s.source = source
-	s.xOffset = float64(s.sourceWidth) / 2
-	s.yOffset = float64(s.sourceHeight) / 2
-
-	// canvas is a gg image context and contains what gets drawn to the screen
-	canvas := gg.NewContext(s.DestWidth*2, s.DestHeight*2)
-	canvas.SetLineWidth(0.0)
-	canvas.SetColor(color.White)
-	canvas.DrawRectangle(s.xOffset, s.yOffset, float64(s.DestWidth), float64(s.DestHeight))
-	canvas.FillPreserve()
-	canvas.Stroke()
-	s.DC = canvas
+	s.Rand = randSource(s.Rand)
+	s.source = prepareSource(source, s.DestWidth, s.DestHeight, s.SourceOptions)
+
+	// canvas contains what gets drawn to the screen
+	s.canvas = image.NewRGBA(image.Rect(0, 0, s.DestWidth, s.DestHeight))
+	draw.Draw(s.canvas, s.canvas.Bounds(), image.White, image.Point{}, draw.Src)
 	return s
 }
 
-// Output trims and returns the canvas as an image
+// Output returns the canvas as an image
 func (s *FlipSketch) Output() image.Image {
-	src := s.DC.Image()
-	bounds := src.Bounds()
-	dst := image.NewRGBA(bounds)
-	draw.Draw(dst, bounds, src, bounds.Min, draw.Src)
-
-	return dst.SubImage(image.Rect(int(s.xOffset), int(s.yOffset), int(s.xOffset)+s.DestWidth, int(s.yOffset)+s.DestHeight))
+	return s.canvas
 }
 
-// Draw performs the algorithm on the image
-func (s *FlipSketch) Draw(divisions int) {
-	r := float64(s.DestHeight) / float64(divisions)
-	maxRows := int(math.Ceil(float64(s.DestHeight) / (r / 1.4142)))
-	maxCols := int(math.Ceil(float64(s.DestWidth) / (r * 1.4142)))
-	//fmt.Println(r, maxRows, maxCols)
-	rot := math.Pi / 4
-	row := 0
-
-	// draw on a bigger workspace than necessary and over draw the bottom and right most edges to make things fit
-	for y := s.yOffset; y < float64(s.DestHeight)+s.yOffset+r/1.4142; y += r / 1.4142 {
-		fmt.Println("row", row)
-		col := 0
-
-		// for debugging, draw markers for the row
-		if false {
-			s.DC.SetLineWidth(10.0)
-			s.DC.DrawLine(s.xOffset, y, s.xOffset+float64(s.DestWidth), y)
-			s.DC.Stroke()
-			s.DC.SetLineWidth(0.0)
+// Draw performs the algorithm on the image, stopping between tiles once ctx is done
+// The canvas is cut into tiles divisions tall and the source is painted through each tile, changed by the transforms
+// each tile rolls for, except near the edges.
+func (s *FlipSketch) Draw(ctx context.Context, divisions int) error {
+	size := float64(s.DestHeight) / float64(divisions)
+	tiles := tiling(s.Tiling, s.DestWidth, s.DestHeight, size, s.Rand)
+	margin := s.Margin * size
+
+	for _, t := range tiles {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
-
-		for x := s.xOffset + 0.7071*r*float64(row%2); x < float64(s.DestWidth)+s.xOffset+r; x += r * 1.4142 {
-			// For each cell, draw the shape and clip it so we only paint the image onto that cell.
-			// Some cells get rotated 180 degrees, some get a white border, but never the edge cells.
-			// This whole thing is a little complicated and pretty slow. I'm probably doing this really inefficiently,
-			// but I can't quite grasp a better way of doing this.
-			// This uses subcontexts from gg to restrict operations to smaller sections of the canvas.
-			s.DC.Push()
-			s.DC.DrawRegularPolygon(4, x, y, r/1.4142, rot)
-			s.DC.Clip()
-			s.DC.Push()
-
-			if (row > 2) && (row < maxRows-1) && (col > 2) && (col < maxCols-1) && (rand.Intn(100) < 25) {
-				s.DC.RotateAbout(math.Pi, x, y)
+		inner := t.x >= margin && t.y >= margin && t.x <= float64(s.DestWidth)-margin && t.y <= float64(s.DestHeight)-margin
+
+		// turn, mirror and zoom about the tile's center, as a matrix taking source points to canvas points
+		m := f64.Aff3{1, 0, 0, 0, 1, 0}
+		var hue, gray, border bool
+		if inner {
+			if s.roll(s.RotateChance) {
+				m = f64.Aff3{-1, 0, 0, 0, -1, 0}
+				if s.RotateQuarter {
+					switch s.Rand.Intn(3) {
+					case 0:
+						m = f64.Aff3{0, -1, 0, 1, 0, 0}
+					case 2:
+						m = f64.Aff3{0, 1, 0, -1, 0, 0}
+					}
+				}
 			}
-
-			s.DC.DrawImage(s.source, int(s.xOffset), int(s.yOffset))
-			s.DC.Fill()
-			s.DC.Stroke()
-
-			if (row > 2) && (row < maxRows-1) && (col > 2) && (col < maxCols-1) && (rand.Intn(100) < 3) {
-				s.DC.Push()
-				s.DC.SetLineWidth(10.0)
-				//s.DC.DrawRegularPolygon(3, x, y, r, rot)
-				s.DC.DrawRegularPolygon(4, x, y, r/1.4142, rot)
-				//s.DC.DrawCircle(x, y, 10)
-				s.DC.Stroke()
-				s.DC.SetLineWidth(0.0)
-				s.DC.Pop()
+			if s.roll(s.MirrorChance) {
+				m[0], m[3] = -m[0], -m[3]
 			}
-
-			// for debugging, mark the center of each cell
-			if false {
-				s.DC.Push()
-				s.DC.SetLineWidth(10.0)
-				s.DC.DrawCircle(x, y, 10)
-				s.DC.Stroke()
-				s.DC.SetLineWidth(0.0)
-				s.DC.Pop()
+			if s.roll(s.ScaleChance) {
+				for _, k := range []int{0, 1, 3, 4} {
+					m[k] *= s.Scale
+				}
 			}
+			hue = s.roll(s.HueChance)
+			gray = s.roll(s.DesaturateChance)
+			border = s.roll(s.BorderChance)
+		}
+		m[2] = t.x - m[0]*t.x - m[1]*t.y
+		m[5] = t.y - m[3]*t.x - m[4]*t.y
+
+		s.blit(t, m, hue, gray, border)
+	}
+	return nil
+}
+
+// roll is true with the given chance, and only uses the random source when the chance isn't zero
+func (s *FlipSketch) roll(chance float64) bool {
+	return chance > 0 && s.Rand.Float64() < chance
+}
+
+// blit paints one tile from the source, only touching the pixels in the tile's bounding box
+// The transformed source is drawn into a tile sized image, recolored, and copied onto the canvas through the tile's
+// hard edged mask, so each pixel along a shared edge is painted by one tile rather than blended from both.
+func (s *FlipSketch) blit(t tile, m f64.Aff3, hue, gray, border bool) {
+	minX, minY, maxX, maxY := t.bounds()
+	r := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).Intersect(s.canvas.Bounds())
+	if r.Empty() {
+		return
+	}
 
-			s.DC.Pop()
+	img := image.NewRGBA(r)
+	if m == (f64.Aff3{1, 0, 0, 0, 1, 0}) {
+		draw.Copy(img, r.Min, s.source, r, draw.Src, nil)
+	} else {
+		draw.ApproxBiLinear.Transform(img, m, s.source, s.source.Bounds(), draw.Src, nil)
+	}
 
-			s.DC.ResetClip()
-			s.DC.Pop()
-			col++
+	mask := t.mask(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if mask.AlphaAt(x, y).A == 0 {
+				continue
+			}
+			px, py := float64(x)+0.5, float64(y)+0.5
+			if border && t.edgeDistance(px, py) < flipBorder {
+				img.SetRGBA(x, y, color.RGBA{255, 255, 255, 255})
+			} else if hue || gray {
+				img.SetRGBA(x, y, s.recolor(img.RGBAAt(x, y), hue, gray))
+			}
 		}
-		row++
 	}
+	draw.DrawMask(s.canvas, r, img, r.Min, mask, r.Min, draw.Over)
+}
 
+// recolor applies the hue shift and desaturation to one pixel
+func (s *FlipSketch) recolor(c color.RGBA, hue, gray bool) color.RGBA {
+	if hue {
+		r, g, b := noire.NewRGB(float64(c.R), float64(c.G), float64(c.B)).AdjustHue(s.HueShift).RGB()
+		c.R, c.G, c.B = uint8(math.Round(r)), uint8(math.Round(g)), uint8(math.Round(b))
+	}
+	if gray {
+		l := uint8(math.Round(255 * luminance(c)))
+		c.R, c.G, c.B = l, l, l
+	}
+	return c
 }
@@ -0,0 +1,151 @@
+package sketch
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/math/f64"
+)
+
+func TestTilingsCoverCanvasOnce(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, kind := range FlipTilings {
+		tiles := tiling(kind, 120, 80, 15, r)
+		for i := 0; i < 300; i++ {
+			x, y := r.Float64()*120, r.Float64()*80
+			n := 0
+			for _, tl := range tiles {
+				if tl.contains(x, y) {
+					n++
+				}
+			}
+			if n != 1 {
+				t.Errorf("%s: point %.2f, %.2f is in %d tiles", kind, x, y, n)
+				break
+			}
+		}
+	}
+}
+
+func TestTileMasksPaintEachPixelOnce(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	canvas := image.Rect(0, 0, 120, 80)
+	for _, kind := range FlipTilings {
+		counts := make([]int, canvas.Dx()*canvas.Dy())
+		for _, tl := range tiling(kind, canvas.Dx(), canvas.Dy(), 15, r) {
+			minX, minY, maxX, maxY := tl.bounds()
+			b := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).Intersect(canvas)
+			mask := tl.mask(b)
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					switch a := mask.AlphaAt(x, y).A; a {
+					case 255:
+						counts[y*canvas.Dx()+x]++
+					case 0:
+					default:
+						t.Fatalf("%s: pixel %d, %d is partly covered, alpha %d", kind, x, y, a)
+					}
+				}
+			}
+		}
+		for i, n := range counts {
+			if n != 1 {
+				t.Errorf("%s: pixel %d, %d is in %d masks", kind, i%canvas.Dx(), i/canvas.Dx(), n)
+			}
+		}
+	}
+}
+
+func TestFlipFitsSourceToCanvas(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	white := color.RGBA{255, 255, 255, 255}
+	type point struct {
+		x, y int
+		want color.RGBA
+	}
+	for _, c := range []struct {
+		name          string
+		source        image.Image
+		width, height int
+		fit, anchor   string
+		points        []point
+	}{
+		// the source is scaled up to the canvas, not drawn at its own size
+		{"cover", halves(40, 20), 80, 40, "cover", "center", []point{{10, 20, red}, {70, 20, blue}}},
+		{"stretch", halves(40, 40), 80, 20, "stretch", "center", []point{{10, 10, red}, {70, 10, blue}}},
+		// a source that doesn't fill the canvas sits at its anchor, leaving the rest white
+		{"none left", halves(20, 20), 80, 20, "none", "left", []point{{5, 10, red}, {15, 10, blue}, {70, 10, white}}},
+		{"none right", halves(20, 20), 80, 20, "none", "right", []point{{10, 10, white}, {65, 10, red}, {75, 10, blue}}},
+		{"contain top", halves(40, 20), 40, 40, "contain", "top", []point{{5, 5, red}, {35, 5, blue}, {20, 35, white}}},
+	} {
+		p := FlipParams{
+			DestWidth:     c.width,
+			DestHeight:    c.height,
+			Tiling:        "diamond",
+			SourceOptions: SourceOptions{Fit: c.fit, Anchor: c.anchor},
+			Random:        Random{Rand: rand.New(rand.NewSource(1))},
+		}
+		s := NewFlipSketch(c.source, p)
+		if err := s.Draw(context.Background(), 4); err != nil {
+			t.Fatal(err)
+		}
+		canvas := s.Output().(*image.RGBA)
+		for _, pt := range c.points {
+			if got := canvas.RGBAAt(pt.x, pt.y); got != pt.want {
+				t.Errorf("%s: at %d, %d got %v, want %v", c.name, pt.x, pt.y, got, pt.want)
+			}
+		}
+	}
+}
+
+func TestFlipBlitOnlyPaintsInsideTile(t *testing.T) {
+	p := FlipParams{DestWidth: 40, DestHeight: 40, SourceOptions: defaultSourceOptions, Random: Random{Rand: rand.New(rand.NewSource(1))}}
+	source := halves(40, 40).(*image.RGBA)
+	s := NewFlipSketch(source, p)
+	tl := tile{20, 20, regularPolygon(3, 20, 20, 15, 0)}
+	s.blit(tl, f64.Aff3{1, 0, 0, 0, 1, 0}, false, false, false)
+
+	canvas := s.Output().(*image.RGBA)
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			want := color.RGBA{255, 255, 255, 255}
+			if tl.contains(float64(x)+0.5+maskNudgeX, float64(y)+0.5+maskNudgeY) {
+				want = source.RGBAAt(x, y)
+			}
+			if got := canvas.RGBAAt(x, y); got != want {
+				t.Fatalf("at %d, %d got %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestFlipBlitTurnsAboutTileCenter(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	white := color.RGBA{255, 255, 255, 255}
+	p := FlipParams{DestWidth: 40, DestHeight: 40, SourceOptions: defaultSourceOptions, Random: Random{Rand: rand.New(rand.NewSource(1))}}
+	s := NewFlipSketch(halves(40, 40), p)
+	tl := tile{20, 20, regularPolygon(4, 20, 20, 10, 0)}
+	// a half turn about the center swaps the tile's left and right halves
+	s.blit(tl, f64.Aff3{-1, 0, 40, 0, -1, 40}, false, false, false)
+
+	canvas := s.Output().(*image.RGBA)
+	for _, c := range []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{15, 20, blue},
+		{25, 20, red},
+		{2, 20, white},
+		{38, 20, white},
+	} {
+		if got := canvas.RGBAAt(c.x, c.y); got != c.want {
+			t.Errorf("at %d, %d got %v, want %v", c.x, c.y, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package sketch
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var (
+	update    = flag.Bool("update", false, "rewrite the golden images in testdata/golden")
+	tolerance = flag.Float64("tolerance", 2.0, "largest mean perceptual difference allowed against a golden image")
+)
+
+const (
+	goldenWidth  = 96
+	goldenHeight = 64
+	goldenSeed   = 42
+	// a pixel further than this from its golden counterpart counts as changed
+	pixelThreshold = 48.0
+	// the share of changed pixels allowed before a render fails
+	changedLimit = 0.01
+)
+
+// testSource is a deterministic stand in for a photo: diagonal color gradients with a bright disc
+func testSource(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	cx, cy := float64(width)*0.6, float64(height)*0.4
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{uint8(255 * x / width), uint8(255 * y / height), uint8(255 * (width - x) / width), 255}
+			if math.Hypot(float64(x)-cx, float64(y)-cy) < float64(height)/4 {
+				c = color.RGBA{240, 220, 90, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func renderGolden(t testing.TB, name string, width, height int) image.Image {
+	r := Renderers[name]
+	opts := RenderOptions{Width: width, Height: height, Iterations: r.Iterations, Seed: goldenSeed}
+	if r.Source {
+		opts.Source = testSource(width, height)
+	}
+	img, err := Render(name, r.Params(width, height, nil), opts)
+	if err != nil {
+		t.Fatalf("render %s: %v", name, err)
+	}
+	return img
+}
+
+func TestGolden(t *testing.T) {
+	for _, name := range SketchNames() {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			got := renderGolden(t, name, goldenWidth, goldenHeight)
+			path := filepath.Join("testdata", "golden", name+".png")
+
+			if *update {
+				if err := writePNG(path, got); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := readPNG(path)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			mean, changed := compareImages(want, got)
+			if mean > *tolerance || changed > changedLimit {
+				t.Errorf("%s differs from golden: mean difference %.2f (limit %.2f), %.2f%% of pixels changed (limit %.2f%%)",
+					name, mean, *tolerance, changed*100, changedLimit*100)
+			}
+		})
+	}
+}
+
+func TestRenderIsRepeatable(t *testing.T) {
+	for _, name := range []string{"crack", "anderson"} {
+		a := renderGolden(t, name, goldenWidth, goldenHeight)
+		b := renderGolden(t, name, goldenWidth, goldenHeight)
+		if mean, _ := compareImages(a, b); mean != 0 {
+			t.Errorf("%s: two renders with the same seed differ by %.2f", name, mean)
+		}
+	}
+}
+
+// compareImages returns the mean perceptual distance between two images and the share of pixels past pixelThreshold
+// Distance is the "redmean" weighted rgb distance, a cheap approximation of how different two colors look.
+func compareImages(a, b image.Image) (mean, changed float64) {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Dx() != bb.Dx() || ab.Dy() != bb.Dy() {
+		return math.Inf(1), 1
+	}
+	total := 0.0
+	count := 0
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			d := colorDistance(a.At(ab.Min.X+x, ab.Min.Y+y), b.At(bb.Min.X+x, bb.Min.Y+y))
+			total += d
+			if d > pixelThreshold {
+				count++
+			}
+		}
+	}
+	pixels := float64(ab.Dx() * ab.Dy())
+	return total / pixels, float64(count) / pixels
+}
+
+func colorDistance(a, b color.Color) float64 {
+	ar, ag, ab, _ := a.RGBA()
+	br, bg, bb, _ := b.RGBA()
+	r1, g1, b1 := float64(ar>>8), float64(ag>>8), float64(ab>>8)
+	r2, g2, b2 := float64(br>>8), float64(bg>>8), float64(bb>>8)
+	rmean := (r1 + r2) / 2
+	dr, dg, db := r1-r2, g1-g2, b1-b2
+	return math.Sqrt((2+rmean/256)*dr*dr+4*dg*dg+(2+(255-rmean)/256)*db*db) / 3
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func writePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
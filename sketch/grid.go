@@ -1,6 +1,8 @@
 package sketch
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
@@ -10,22 +12,70 @@ import (
 	"gitlab.com/ericworkman/generative/util"
 )
 
+// GridShapes are the marks drawn at each point of the grid
+var GridShapes = []string{"circle", "square", "hexagon", "polygon", "line"}
+
+// GridLattices are the ways grid points can be laid out
+var GridLattices = []string{"square", "hex", "jitter"}
+
+// GridVignettes are the ways the grid can fade towards the edges
+var GridVignettes = []string{"none", "x", "radial", "elliptical"}
+
 // GridParams contains externally-provided parameters
 type GridParams struct {
 	// tweakable parameters for the cli
 	DestWidth  int
 	DestHeight int
-	Vignette   bool
 	Size       float64
+	SourceOptions
+	// Shape is the mark drawn at each point, one of GridShapes
+	Shape string
+	// Sides is the number of sides of a polygon mark
+	Sides int
+	// Halftone sizes each mark by the brightness of the source under it, so the marks on the black canvas light it like the source
+	Halftone bool
+	// Lattice is how the points are laid out, one of GridLattices
+	Lattice string
+	// Jitter is how far a jittered point can move from the square lattice, as a fraction of Size
+	Jitter float64
+	// Vignette is how the grid fades towards the edges, one of GridVignettes
+	// none keeps every mark opaque, x fades towards the left and right edges, radial fades in a circle and elliptical
+	// stretches the circle to the canvas.
+	Vignette string
+	// Rotation turns the lattice and the marks about the center of the canvas, in degrees
+	Rotation float64
+	Random
+}
+
+// Validate rejects unknown shapes, lattices and vignettes, a size under 1 and polygons with too few or too many sides
+func (p GridParams) Validate() error {
+	// a mark for every pixel is as fine as the grid can get
+	if p.Size < 1 {
+		return errors.New("size must be at least 1")
+	}
+	if err := checkChoice("shape", p.Shape, GridShapes); err != nil {
+		return err
+	}
+	if p.Shape == "polygon" && (p.Sides < 3 || p.Sides > maxSides) {
+		return fmt.Errorf("polygons need between 3 and %d sides", maxSides)
+	}
+	if err := checkChoice("lattice", p.Lattice, GridLattices); err != nil {
+		return err
+	}
+	if p.Jitter < 0 {
+		return errors.New("jitter can't be negative")
+	}
+	if err := checkChoice("vignette", p.Vignette, GridVignettes); err != nil {
+		return err
+	}
+	return p.SourceOptions.Validate()
 }
 
 // GridSketch wraps all the components needed to draw the sketch
 type GridSketch struct {
 	GridParams
-	DC           *gg.Context
-	source       image.Image
-	sourceWidth  int
-	sourceHeight int
+	DC     *gg.Context
+	source *image.RGBA
 }
 
 // NewGridSketch initializes the canvas and GridSketch
@@ -33,9 +83,8 @@ func NewGridSketch(source image.Image, params GridParams) *GridSketch {
 	fmt.Println("Starting Sketch")
 
 	s := &GridSketch{GridParams: params}
-	bounds := source.Bounds()
-	s.sourceWidth, s.sourceHeight = bounds.Max.X, bounds.Max.Y
-	s.source = source
+	s.Rand = randSource(s.Rand)
+	s.source = prepareSource(source, s.DestWidth, s.DestHeight, s.SourceOptions)
 
 	// canvas is a gg image context and contains what gets drawn to the screen
 	canvas := gg.NewContext(s.DestWidth, s.DestHeight)
@@ -44,29 +93,113 @@ func NewGridSketch(source image.Image, params GridParams) *GridSketch {
 	canvas.DrawRectangle(0, 0, float64(s.DestWidth), float64(s.DestHeight))
 	canvas.FillPreserve()
 	canvas.Stroke()
+	canvas.SetLineCapButt()
 	s.DC = canvas
 
 	s.DC.SetLineWidth(0.0)
 	return s
 }
 
-// Draw completes the drawing
-func (s *GridSketch) Draw() {
-	spacing := s.Size
-	for x := spacing; x < float64(s.sourceWidth); x += spacing {
-		alpha := 255.0
-		if s.Vignette {
-			alpha = 255 - math.Abs(255.0*(float64(s.sourceWidth/2)-x)/float64(s.sourceWidth/2))
+// Draw completes the drawing, stopping between marks once ctx is done
+func (s *GridSketch) Draw(ctx context.Context) error {
+	for _, p := range s.points() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		x, y := p.X, p.Y
+		r, g, b, ok := sourceAt(s.source, x, y)
+		if !ok {
+			continue
 		}
-		for y := spacing; y < float64(s.sourceHeight); y += spacing {
-			r, g, b := util.Rgb255(s.source.At(int(x), int(y)))
-			s.DC.SetRGBA255(r, g, b, int(alpha))
-			s.DC.DrawCircle(float64(x), float64(y), float64(spacing/2))
-			s.DC.FillPreserve()
-			s.DC.Stroke()
+		alpha := 255 * math.Max(0, 1-s.vignette(x, y))
+		scale := 1.0
+		if s.Halftone {
+			scale = luminance(color.RGBA{uint8(r), uint8(g), uint8(b), 255})
 		}
+		s.DC.SetRGBA255(r, g, b, int(alpha))
+		s.mark(x, y, scale)
 	}
+	return nil
+}
+
+// points lays out the lattice, turned about the center of the canvas, keeping the points on the canvas
+// Rows and columns are counted out from the top left so an unturned square lattice starts one spacing in from the edges.
+func (s *GridSketch) points() []gg.Point {
+	spacing := s.Size
+	rowSpacing := spacing
+	if s.Lattice == "hex" {
+		rowSpacing = spacing * math.Sqrt(3) / 2
+	}
+	cx, cy := float64(s.DestWidth)/2, float64(s.DestHeight)/2
+	// a turned lattice has to reach the corners from the center
+	reach := math.Hypot(cx, cy)
+	angle := s.Rotation * math.Pi / 180
+	sin, cos := math.Sincos(angle)
+
+	var points []gg.Point
+	for i := math.Floor((cx - reach) / spacing); i*spacing <= cx+reach; i++ {
+		for j := math.Floor((cy - reach) / rowSpacing); j*rowSpacing <= cy+reach; j++ {
+			x, y := i*spacing, j*rowSpacing
+			switch s.Lattice {
+			case "hex":
+				if int(j)%2 != 0 {
+					x += spacing / 2
+				}
+			case "jitter":
+				x += util.RandFloat64Range(s.Rand, s.Jitter*spacing)
+				y += util.RandFloat64Range(s.Rand, s.Jitter*spacing)
+			}
+			if angle != 0 {
+				x, y = cx+(x-cx)*cos-(y-cy)*sin, cy+(x-cx)*sin+(y-cy)*cos
+			}
+			if x > 0 && y > 0 && x < float64(s.DestWidth) && y < float64(s.DestHeight) {
+				points = append(points, gg.Point{X: x, Y: y})
+			}
+		}
+	}
+	return points
+}
+
+// vignette is how far a point is towards the edge, 0 at the center and 1 at the edge, and always 0 without a vignette
+func (s *GridSketch) vignette(x, y float64) float64 {
+	cx, cy := float64(s.DestWidth/2), float64(s.DestHeight/2)
+	switch s.Vignette {
+	case "x":
+		return math.Abs(cx-x) / cx
+	case "radial":
+		return math.Hypot(x-cx, y-cy) / math.Min(cx, cy)
+	case "elliptical":
+		return math.Hypot((x-cx)/cx, (y-cy)/cy)
+	}
+	return 0
+}
 
+// mark draws the shape at a point, scale is the share of the cell a halftone mark covers
+func (s *GridSketch) mark(x, y, scale float64) {
+	// marks cover their share of the cell's area, so their size goes with the square root
+	radius := s.Size / 2 * math.Sqrt(scale)
+	angle := s.Rotation * math.Pi / 180
+	switch s.Shape {
+	case "square":
+		s.DC.DrawRegularPolygon(4, x, y, radius*math.Sqrt2, angle)
+	case "hexagon":
+		// pointy topped, so hexagons on a hex lattice meet along their sides
+		s.DC.DrawRegularPolygon(6, x, y, radius*2/math.Sqrt(3), angle+math.Pi/6)
+	case "polygon":
+		s.DC.DrawRegularPolygon(s.Sides, x, y, radius, angle)
+	case "line":
+		// lines run the whole cell so they join into stripes, and their width carries the halftone
+		dx, dy := s.Size/2*math.Cos(angle), s.Size/2*math.Sin(angle)
+		s.DC.SetLineWidth(s.Size / 2 * scale)
+		s.DC.DrawLine(x-dx, y-dy, x+dx, y+dy)
+		s.DC.Stroke()
+		s.DC.SetLineWidth(0.0)
+		return
+	default:
+		s.DC.DrawCircle(x, y, radius)
+	}
+	s.DC.FillPreserve()
+	s.DC.Stroke()
 }
 
 // Output produces an image output of the current state of the sketch
@@ -0,0 +1,52 @@
+package sketch
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func gridParams() GridParams {
+	return GridParams{DestWidth: 200, DestHeight: 100, Size: 20, SourceOptions: SourceOptions{Fit: "cover", Anchor: "center"}, Shape: "circle", Lattice: "square", Vignette: "none", Random: Random{Rand: rand.New(rand.NewSource(1))}}
+}
+
+func TestGridSquareLatticeStartsOneSpacingIn(t *testing.T) {
+	s := &GridSketch{GridParams: gridParams()}
+	points := s.points()
+	// 9 columns and 4 rows fit strictly inside 200 by 100 at a spacing of 20
+	if len(points) != 36 {
+		t.Fatalf("got %d points, want 36", len(points))
+	}
+	if p := points[0]; p.X != 20 || p.Y != 20 {
+		t.Errorf("first point is %v, want 20, 20", p)
+	}
+}
+
+func TestGridTurnedLatticeStaysOnCanvas(t *testing.T) {
+	for _, lattice := range GridLattices {
+		p := gridParams()
+		p.Lattice = lattice
+		p.Rotation = 30
+		s := &GridSketch{GridParams: p}
+		points := s.points()
+		// a turned lattice still fills the canvas at about one point per cell
+		if len(points) < 40 || len(points) > 60 {
+			t.Errorf("%s: got %d points, want about 50", lattice, len(points))
+		}
+		for _, pt := range points {
+			if pt.X <= 0 || pt.Y <= 0 || pt.X >= 200 || pt.Y >= 100 {
+				t.Errorf("%s: point %v is off the canvas", lattice, pt)
+			}
+		}
+	}
+}
+
+func TestGridVignette(t *testing.T) {
+	s := &GridSketch{GridParams: gridParams()}
+	for shape, want := range map[string]float64{"none": 0, "x": 0.5, "radial": math.Hypot(50, 25) / 50, "elliptical": 0.5 * math.Sqrt2} {
+		s.Vignette = shape
+		if got := s.vignette(150, 75); math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s: got %.3f, want %.3f", shape, got, want)
+		}
+	}
+}
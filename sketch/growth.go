@@ -1,13 +1,17 @@
 package sketch
 
 import (
+	"container/heap"
+	"context"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
-	"math/rand"
+	"math"
 
 	"github.com/fogleman/gg"
 	"github.com/teacat/noire"
+	"gitlab.com/ericworkman/generative/util"
 )
 
 var (
@@ -40,6 +44,37 @@ type GrowthParams struct {
 	DestWidth     int
 	DestHeight    int
 	StartingSeeds int
+	// Palette replaces the default crystal colors when it is not empty
+	Palette [][3]int
+	// Metric is how distance from a seed is measured: chebyshev grows squares, manhattan diamonds and euclidean circles
+	Metric string
+	// SpeedVariance gives each seed a growth speed between 1-SpeedVariance and 1+SpeedVariance
+	SpeedVariance float64
+	// MaxDelay gives each seed a start delay of up to MaxDelay steps
+	MaxDelay float64
+	// Anisotropy stretches each seed's growth by up to 1+Anisotropy along a random direction
+	Anisotropy float64
+	Random
+}
+
+// GrowthMetrics are the accepted values of GrowthParams.Metric
+var GrowthMetrics = []string{"chebyshev", "manhattan", "euclidean"}
+
+// Validate rejects unknown metrics, more seeds than pixels, and speed variance, delay or anisotropy that would stall a crystal
+func (p GrowthParams) Validate() error {
+	if err := checkCount("starting seeds", p.StartingSeeds, p.DestWidth, p.DestHeight); err != nil {
+		return err
+	}
+	if err := checkOptionalChoice("metric", p.Metric, GrowthMetrics); err != nil {
+		return err
+	}
+	if p.SpeedVariance < 0 || p.SpeedVariance >= 1 {
+		return errors.New("speed variance must be at least 0 and less than 1")
+	}
+	if p.MaxDelay < 0 || p.Anisotropy < 0 {
+		return errors.New("max delay and anisotropy can't be negative")
+	}
+	return nil
 }
 
 // GrowthSketch wraps all the components needed to draw the sketch
@@ -47,8 +82,12 @@ type GrowthSketch struct {
 	GrowthParams
 	DC    *gg.Context
 	Seeds []seed
+	// owner holds the index of the seed that claimed each pixel, or unowned
+	owner []int32
 }
 
+const unowned = -1
+
 type seed struct {
 	x      int
 	y      int
@@ -58,6 +97,25 @@ type seed struct {
 	colorG int
 	colorB int
 	grew   bool
+	// frontier contains the pixels claimed in the last step, the only ones that can grow further
+	frontier []int
+	// weighting, all seeds share speed 1, no delay and a stretch of 1 unless asked otherwise
+	speed   float64
+	delay   float64
+	stretch float64
+	angle   float64
+}
+
+// neighbors are the offsets grown into from a frontier pixel, all eight around it keeps the growth square
+var neighbors = [...][2]int{
+	{-1, -1}, {0, -1}, {1, -1},
+	{-1, 0}, {1, 0},
+	{-1, 1}, {0, 1}, {1, 1},
+}
+
+// edgeNeighbors only shares edges, which keeps manhattan growth to diamonds
+var edgeNeighbors = [...][2]int{
+	{0, -1}, {-1, 0}, {1, 0}, {0, 1},
 }
 
 // NewGrowthSketch initializes the canvas and GrowthSketch
@@ -65,6 +123,7 @@ func NewGrowthSketch(params GrowthParams) *GrowthSketch {
 	fmt.Println("Starting Sketch")
 
 	s := &GrowthSketch{GrowthParams: params}
+	s.Rand = randSource(s.Rand)
 
 	// canvas is a gg image context and contains what gets drawn to the screen
 	canvas := gg.NewContext(s.DestWidth, s.DestHeight)
@@ -75,10 +134,31 @@ func NewGrowthSketch(params GrowthParams) *GrowthSketch {
 	canvas.Stroke()
 	s.DC = canvas
 
+	s.owner = make([]int32, s.DestWidth*s.DestHeight)
+	for i := range s.owner {
+		s.owner[i] = unowned
+	}
+
 	for i := 0; i < s.StartingSeeds; i++ {
-		c := crystalColors[rand.Intn(len(crystalColors))]
+		c := crystalColors[s.Rand.Intn(len(crystalColors))]
+		if len(s.Palette) > 0 {
+			p := s.Palette[s.Rand.Intn(len(s.Palette))]
+			c = noire.NewRGB(float64(p[0]), float64(p[1]), float64(p[2]))
+		}
 		r, g, b := c.RGB()
-		s.Seeds = append(s.Seeds, seed{x: rand.Intn(s.DestWidth), y: rand.Intn(s.DestHeight), r: 0, c: c, colorR: int(r), colorG: int(g), colorB: int(b)})
+		sd := seed{x: s.Rand.Intn(s.DestWidth), y: s.Rand.Intn(s.DestHeight), r: 0, c: c, colorR: int(r), colorG: int(g), colorB: int(b), speed: 1, stretch: 1}
+		// only draw the weights when asked for, so plain sketches keep the same seeds
+		if s.SpeedVariance > 0 {
+			sd.speed = 1 + util.RandFloat64Range(s.Rand, s.SpeedVariance)
+		}
+		if s.MaxDelay > 0 {
+			sd.delay = s.Rand.Float64() * s.MaxDelay
+		}
+		if s.Anisotropy > 0 {
+			sd.stretch = 1 + s.Rand.Float64()*s.Anisotropy
+			sd.angle = s.Rand.Float64() * math.Pi
+		}
+		s.Seeds = append(s.Seeds, sd)
 	}
 
 	return s
@@ -89,47 +169,171 @@ func (s *GrowthSketch) Output() image.Image {
 	return s.DC.Image()
 }
 
-// Draw completes the drawing
-func (s *GrowthSketch) Draw() {
-	// grow the seeds until there is no room left
-	// expand all "borders" unless a color already exists there
-	// track which seeds grow in an iteration
-	// stopping point for iterations is set as higher that it should ever take, shortcut later when all seeds stop
-	for k := 0; k < s.DestHeight*s.DestWidth; k++ {
-		for i := 0; i < len(s.Seeds); i++ {
-			seed := s.Seeds[i]
-			seed.grew = false
-
-			s.DC.SetRGB255(seed.colorR, seed.colorG, seed.colorB)
-
-			for m := -seed.r; m <= seed.r; m++ {
-				for n := -seed.r; n <= seed.r; n++ {
-					x := seed.x + m
-					y := seed.y + n
-
-					if x >= 0 && x <= int(s.DestWidth) && y >= 0 && y <= int(s.DestHeight) {
-						cr, cg, cb, _ := s.DC.Image().At(x, y).RGBA()
-						// image is white, which is 65535 in all channels
-						if cr == 65535 && cg == 65535 && cb == 65535 {
-							s.DC.SetPixel(x, y)
-							seed.grew = true
-						}
+// claim marks a pixel as belonging to seed i and paints it in the seed's color
+func (s *GrowthSketch) claim(img *image.RGBA, p int, i int) {
+	s.owner[p] = int32(i)
+	seed := &s.Seeds[i]
+	o := img.PixOffset(p%s.DestWidth, p/s.DestWidth)
+	img.Pix[o] = uint8(seed.colorR)
+	img.Pix[o+1] = uint8(seed.colorG)
+	img.Pix[o+2] = uint8(seed.colorB)
+	img.Pix[o+3] = 255
+}
+
+// Draw completes the drawing, stopping between steps once ctx is done
+func (s *GrowthSketch) Draw(ctx context.Context) error {
+	img := s.DC.Image().(*image.RGBA)
+
+	// weighted seeds reach pixels at different times, which needs the ordering of a priority queue
+	// otherwise every seed grows one ring per step, and plain breadth first growth is enough
+	if s.SpeedVariance > 0 || s.MaxDelay > 0 || s.Anisotropy > 0 || s.Metric == "euclidean" {
+		return s.drawWeighted(ctx, img)
+	}
+
+	for i := range s.Seeds {
+		p := s.Seeds[i].y*s.DestWidth + s.Seeds[i].x
+		if s.owner[p] == unowned {
+			s.claim(img, p, i)
+			s.Seeds[i].frontier = []int{p}
+		}
+	}
+	return s.drawSteps(ctx, img)
+}
+
+// drawSteps grows the seeds one ring at a time until there is no room left
+// every step, each seed claims the unowned pixels touching the ones it claimed the step before
+// earlier seeds win any pixel reached by several seeds in the same step
+// each pixel is claimed once and looked at from at most eight neighbors, so this is linear in the number of pixels
+func (s *GrowthSketch) drawSteps(ctx context.Context, img *image.RGBA) error {
+	offsets := neighbors[:]
+	if s.Metric == "manhattan" {
+		offsets = edgeNeighbors[:]
+	}
+
+	for growing := true; growing; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		growing = false
+		for i := range s.Seeds {
+			var next []int
+			for _, p := range s.Seeds[i].frontier {
+				px, py := p%s.DestWidth, p/s.DestWidth
+				for _, n := range offsets {
+					x, y := px+n[0], py+n[1]
+					if x < 0 || x >= s.DestWidth || y < 0 || y >= s.DestHeight {
+						continue
+					}
+					q := y*s.DestWidth + x
+					if s.owner[q] == unowned {
+						s.claim(img, q, i)
+						next = append(next, q)
 					}
 				}
 			}
 
-			seed.r++
-			s.Seeds[i] = seed
+			s.Seeds[i].frontier = next
+			s.Seeds[i].grew = len(next) > 0
+			if s.Seeds[i].grew {
+				s.Seeds[i].r++
+				growing = true
+			}
+		}
+	}
+	return nil
+}
+
+// arrival is when a seed would reach a pixel
+type arrival struct {
+	t    float64
+	seed int32
+	p    int32
+}
+
+type arrivalQueue []arrival
+
+func (q arrivalQueue) Len() int { return len(q) }
+func (q arrivalQueue) Less(i, j int) bool {
+	if q[i].t == q[j].t {
+		return q[i].seed < q[j].seed
+	}
+	return q[i].t < q[j].t
+}
+func (q arrivalQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *arrivalQueue) Push(x interface{}) { *q = append(*q, x.(arrival)) }
+func (q *arrivalQueue) Pop() interface{} {
+	old := *q
+	a := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return a
+}
+
+// arrivalTime is the time seed i reaches pixel x, y: its delay plus its weighted distance over its speed
+func (s *GrowthSketch) arrivalTime(i int, x, y int) float64 {
+	sd := &s.Seeds[i]
+	dx := float64(x - sd.x)
+	dy := float64(y - sd.y)
+	if sd.stretch != 1 {
+		// rotate into the seed's stretch direction and shrink distances along it, so the seed grows faster that way
+		cos, sin := math.Cos(sd.angle), math.Sin(sd.angle)
+		dx, dy = (dx*cos+dy*sin)/sd.stretch, -dx*sin+dy*cos
+	}
+
+	d := 0.0
+	switch s.Metric {
+	case "euclidean":
+		d = math.Hypot(dx, dy)
+	case "manhattan":
+		d = math.Abs(dx) + math.Abs(dy)
+	default:
+		d = math.Max(math.Abs(dx), math.Abs(dy))
+	}
+	return sd.delay + d/sd.speed
+}
+
+// drawWeighted claims pixels in order of arrival time, growing only into pixels next to ones a seed already owns
+// so slow seeds get walled in by fast ones, rather than appearing on the far side of them as in a plain voronoi diagram
+func (s *GrowthSketch) drawWeighted(ctx context.Context, img *image.RGBA) error {
+	offsets := neighbors[:]
+	if s.Metric == "manhattan" {
+		offsets = edgeNeighbors[:]
+	}
+
+	q := &arrivalQueue{}
+	push := func(i, p int) {
+		px, py := p%s.DestWidth, p/s.DestWidth
+		for _, n := range offsets {
+			x, y := px+n[0], py+n[1]
+			if x < 0 || x >= s.DestWidth || y < 0 || y >= s.DestHeight {
+				continue
+			}
+			if n := y*s.DestWidth + x; s.owner[n] == unowned {
+				heap.Push(q, arrival{t: s.arrivalTime(i, x, y), seed: int32(i), p: int32(n)})
+			}
 		}
-		// check if all seeds have stopped growing
-		someSeedsContinuing := false
-		for _, seed := range s.Seeds {
-			if seed.grew == true {
-				someSeedsContinuing = true
+	}
+
+	// each seed reaches its own center once its delay is over, a seed grown over before then never starts
+	for i := range s.Seeds {
+		sd := &s.Seeds[i]
+		heap.Push(q, arrival{t: sd.delay, seed: int32(i), p: int32(sd.y*s.DestWidth + sd.x)})
+	}
+
+	for n := 0; q.Len() > 0; n++ {
+		// checking the context is slow next to a pop, so only look every so often
+		if n%4096 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
 			}
 		}
-		if someSeedsContinuing != true {
-			k = s.DestHeight * s.DestWidth
+		a := heap.Pop(q).(arrival)
+		if s.owner[a.p] != unowned {
+			continue
 		}
+		i := int(a.seed)
+		s.claim(img, int(a.p), i)
+		s.Seeds[i].r = util.MaxInt(s.Seeds[i].r, int(a.t))
+		push(i, int(a.p))
 	}
+	return nil
 }
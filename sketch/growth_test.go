@@ -0,0 +1,40 @@
+package sketch
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGrowthFillsCanvas(t *testing.T) {
+	for _, metric := range GrowthMetrics {
+		for _, weighted := range []bool{false, true} {
+			p := GrowthParams{DestWidth: 60, DestHeight: 40, StartingSeeds: 6, Metric: metric}
+			if weighted {
+				p.SpeedVariance, p.MaxDelay, p.Anisotropy = 0.5, 10, 1
+			}
+			s := NewGrowthSketch(p)
+			if err := s.Draw(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			for i, o := range s.owner {
+				if o == unowned {
+					t.Fatalf("%s weighted=%v: pixel %d was never claimed", metric, weighted, i)
+				}
+			}
+		}
+	}
+}
+
+func TestGrowthDelayedSeedCanBeGrownOver(t *testing.T) {
+	p := GrowthParams{DestWidth: 40, DestHeight: 20, StartingSeeds: 2, MaxDelay: 1}
+	s := NewGrowthSketch(p)
+	// the second seed starts long after the first has covered the canvas
+	s.Seeds[0].x, s.Seeds[0].y, s.Seeds[0].delay = 30, 10, 0
+	s.Seeds[1].x, s.Seeds[1].y, s.Seeds[1].delay = 10, 10, 1000
+	if err := s.Draw(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if o := s.owner[10*40+10]; o != 0 {
+		t.Errorf("the delayed seed's center belongs to seed %d, want the first seed", o)
+	}
+}
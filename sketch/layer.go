@@ -1,12 +1,13 @@
 package sketch
 
 import (
+	"errors"
+	"fmt"
 	"image"
-	"image/color"
 	"math"
-	"math/rand"
 
 	"github.com/fogleman/gg"
+	"gitlab.com/ericworkman/generative/blend"
 	"gitlab.com/ericworkman/generative/util"
 )
 
@@ -24,15 +25,33 @@ type LayerParams struct {
 	MaxEdgeCount           int
 	Edge                   bool
 	PathInversionThreshold float64
+	SourceOptions
+	HDROptions
+	Random
+}
+
+// Validate rejects a path min that paths would never shrink below, too many edges, and bad source or hdr options
+func (p LayerParams) Validate() error {
+	// paths shrink until they are smaller than PathMin, which never happens when it isn't positive
+	if p.PathMin <= 0 {
+		return errors.New("path min must be positive")
+	}
+	if p.MaxEdgeCount > maxSides {
+		return fmt.Errorf("shapes can have at most %d edges", maxSides)
+	}
+	if err := p.SourceOptions.Validate(); err != nil {
+		return err
+	}
+	return p.HDROptions.Validate()
 }
 
 // LayerSketch is the wrapping container
 type LayerSketch struct {
 	LayerParams
-	source          image.Image
-	DC              *gg.Context
-	sourceWidth     int
-	sourceHeight    int
+	source *image.RGBA
+	canvas blend.Surface
+	// shapes are rasterized into scratch at full strength, then blended into the canvas by their coverage
+	scratch         *gg.Context
 	InitialPathSize float64
 	PathSize        float64
 }
@@ -40,62 +59,107 @@ type LayerSketch struct {
 // NewLayerSketch creates a new layer sketch
 func NewLayerSketch(source image.Image, layerParams LayerParams) *LayerSketch {
 	s := &LayerSketch{LayerParams: layerParams}
-	bounds := source.Bounds()
-	s.sourceWidth, s.sourceHeight = bounds.Max.X, bounds.Max.Y
+	s.Rand = randSource(s.Rand)
 	s.PathSize = s.PathRatio * float64(s.DestWidth)
 	s.InitialPathSize = s.PathSize
 
-	canvas := gg.NewContext(s.DestWidth, s.DestHeight)
-	canvas.SetColor(color.Black)
-	canvas.DrawRectangle(0, 0, float64(s.sourceWidth), float64(s.sourceHeight))
-	canvas.FillPreserve()
+	s.canvas = newSurface(s.DestWidth, s.DestHeight, [3]int{0, 0, 0}, s.HDROptions)
+	s.scratch = gg.NewContext(s.DestWidth, s.DestHeight)
+	s.scratch.SetRGB(1, 1, 1)
 
-	s.source = source
-	s.DC = canvas
+	s.source = prepareSource(source, s.DestWidth, s.DestHeight, s.SourceOptions)
 	return s
 }
 
 // Output saves the canvas as an image
 func (s *LayerSketch) Output() image.Image {
-	return s.DC.Image()
+	return s.canvas.Image()
 }
 
 // Update performs a single iteration
 func (s *LayerSketch) Update() {
-	rndX := rand.Float64() * float64(s.sourceWidth)
-	rndY := rand.Float64() * float64(s.sourceHeight)
-	r, g, b := util.Rgb255(s.source.At(int(rndX), int(rndY)))
+	rndX := s.Rand.Float64() * float64(s.DestWidth)
+	rndY := s.Rand.Float64() * float64(s.DestHeight)
+	r, g, b, ok := sourceAt(s.source, rndX, rndY)
 
-	destX := rndX * float64(s.DestWidth) / float64(s.sourceWidth)
-	destX += float64(util.RandRange(s.PathJitter))
-	destY := rndY * float64(s.DestHeight) / float64(s.sourceHeight)
-	destY += float64(util.RandRange(s.PathJitter))
-
-	s.DC.SetRGBA255(r, g, b, int(s.InitialAlpha))
-	edges := s.MinEdgeCount + rand.Intn(s.MaxEdgeCount-s.MinEdgeCount+1)
-	if edges < 2 {
-		s.DC.DrawCircle(destX, destY, s.PathSize)
-		s.DC.FillPreserve()
-	} else if edges == 2 {
-		s.DC.SetLineWidth(10.00)
-		randAngle := rand.Float64() * float64(360)
-		s.DC.DrawLine(destX, destY, destX+s.PathSize*math.Cos(randAngle), destY+s.PathSize*math.Sin(randAngle))
-		s.DC.StrokePreserve()
-	} else {
-		s.DC.DrawRegularPolygon(edges, destX, destY, s.PathSize, rand.Float64())
-		s.DC.FillPreserve()
+	destX := rndX + float64(util.RandRange(s.Rand, s.PathJitter))
+	destY := rndY + float64(util.RandRange(s.Rand, s.PathJitter))
+	if !ok {
+		// the source doesn't cover this point, so only shrink the path
+		s.shrink()
+		return
 	}
 
+	// alpha is on a 0-255 scale, kept fractional so the first faint shapes still show
+	fill, fillAlpha := [3]int{r, g, b}, s.InitialAlpha/255
+	edge, edgeAlpha := fill, fillAlpha
 	if s.Edge && s.PathSize <= s.PathInversionThreshold*s.InitialPathSize {
+		edgeAlpha *= 2
 		if (r+g+b)/3 < 128 {
-			s.DC.SetRGBA255(255, 255, 255, int(s.InitialAlpha*2))
+			edge = [3]int{255, 255, 255}
 		} else {
-			s.DC.SetRGBA255(0, 0, 0, int(s.InitialAlpha*2))
+			edge = [3]int{0, 0, 0}
+		}
+	}
+
+	// every shape fits inside its path size around the destination, plus the width of a line
+	reach := s.PathSize + 10
+	bounds := image.Rect(int(destX-reach), int(destY-reach), int(destX+reach)+1, int(destY+reach)+1)
+	edges := s.MinEdgeCount + s.Rand.Intn(s.MaxEdgeCount-s.MinEdgeCount+1)
+	if edges < 2 {
+		s.paint(bounds, fill, fillAlpha, func(dc *gg.Context) {
+			dc.DrawCircle(destX, destY, s.PathSize)
+			dc.Fill()
+		})
+		s.paint(bounds, edge, edgeAlpha, func(dc *gg.Context) {
+			dc.DrawCircle(destX, destY, s.PathSize)
+			dc.Stroke()
+		})
+	} else if edges == 2 {
+		randAngle := s.Rand.Float64() * float64(360)
+		line := func(dc *gg.Context) {
+			dc.SetLineWidth(10.00)
+			dc.DrawLine(destX, destY, destX+s.PathSize*math.Cos(randAngle), destY+s.PathSize*math.Sin(randAngle))
+			dc.Stroke()
 		}
+		s.paint(bounds, fill, fillAlpha, line)
+		s.paint(bounds, edge, edgeAlpha, line)
+	} else {
+		rotation := s.Rand.Float64()
+		s.paint(bounds, fill, fillAlpha, func(dc *gg.Context) {
+			dc.DrawRegularPolygon(edges, destX, destY, s.PathSize, rotation)
+			dc.Fill()
+		})
+		s.paint(bounds, edge, edgeAlpha, func(dc *gg.Context) {
+			dc.DrawRegularPolygon(edges, destX, destY, s.PathSize, rotation)
+			dc.Stroke()
+		})
 	}
 
-	s.DC.Stroke()
+	s.shrink()
+}
+
+// paint rasterizes a shape at full strength, then blends the color into the canvas by how much of each pixel
+// the shape covers, bounds holds the whole shape
+func (s *LayerSketch) paint(bounds image.Rectangle, c [3]int, alpha float64, draw func(dc *gg.Context)) {
+	s.scratch.SetLineWidth(1)
+	draw(s.scratch)
+	mask := s.scratch.Image().(*image.RGBA)
+	bounds = bounds.Intersect(mask.Bounds())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := mask.PixOffset(x, y)
+			if coverage := mask.Pix[i+3]; coverage > 0 {
+				s.canvas.Blend(x, y, c, alpha*float64(coverage)/255, blend.Normal)
+				// clear the scratch as it's read so the next shape starts on nothing
+				mask.Pix[i], mask.Pix[i+1], mask.Pix[i+2], mask.Pix[i+3] = 0, 0, 0, 0
+			}
+		}
+	}
+}
 
+// shrink makes the next path smaller and more opaque
+func (s *LayerSketch) shrink() {
 	s.PathSize -= s.PathReduction * s.PathSize
 	s.InitialAlpha += s.AlphaIncrease
 }
@@ -0,0 +1,32 @@
+package sketch
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestLayerFaintShapesAddUp(t *testing.T) {
+	// shapes start well under one 0-255 step of alpha, and only show once enough of them overlap
+	source := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(source, source.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	for _, hdr := range []bool{false, true} {
+		p := LayerParams{
+			DestWidth:     20,
+			DestHeight:    20,
+			PathRatio:     2,
+			PathMin:       1,
+			InitialAlpha:  0.1,
+			SourceOptions: defaultSourceOptions,
+			HDROptions:    HDROptions{HDR: hdr, Gamma: 1},
+		}
+		s := NewLayerSketch(source, p)
+		for i := 0; i < 50; i++ {
+			s.Update()
+		}
+		if c := s.Output().(*image.RGBA).RGBAAt(10, 10); c.R == 0 {
+			t.Errorf("hdr %v: 50 shapes at alpha 0.1 left the canvas black", hdr)
+		}
+	}
+}
@@ -4,25 +4,23 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"math/rand"
 
 	"github.com/fogleman/gg"
-	"gitlab.com/ericworkman/generative/util"
 )
 
 // MondrianParams contains user input
 type MondrianParams struct {
 	DestWidth  int
 	DestHeight int
+	SourceOptions
+	Random
 }
 
 // MondrianSketch is the canvas and grid wrapper
 type MondrianSketch struct {
 	MondrianParams
-	source       image.Image
-	DC           *gg.Context
-	sourceWidth  int
-	sourceHeight int
+	source *image.RGBA
+	DC     *gg.Context
 }
 
 // NewMondrianSketch creates a stack sketch
@@ -30,9 +28,8 @@ func NewMondrianSketch(source image.Image, params MondrianParams) *MondrianSketc
 	fmt.Println("Starting Sketch")
 
 	s := &MondrianSketch{MondrianParams: params}
-	bounds := source.Bounds()
-	s.sourceWidth, s.sourceHeight = bounds.Max.X, bounds.Max.Y
-	s.source = source
+	s.Rand = randSource(s.Rand)
+	s.source = prepareSource(source, s.DestWidth, s.DestHeight, s.SourceOptions)
 
 	// canvas is a gg image context and contains what gets drawn to the screen
 	canvas := gg.NewContext(s.DestWidth, s.DestHeight)
@@ -40,7 +37,7 @@ func NewMondrianSketch(source image.Image, params MondrianParams) *MondrianSketc
 	canvas.SetColor(color.White)
 	canvas.DrawRectangle(0, 0, float64(s.DestWidth), float64(s.DestHeight))
 	canvas.FillPreserve()
-	canvas.DrawImage(source, 0, 0)
+	canvas.DrawImage(s.source, 0, 0)
 	canvas.Stroke()
 	s.DC = canvas
 	return s
@@ -53,14 +50,14 @@ func (s *MondrianSketch) Output() image.Image {
 
 // Update performs a single iteration
 func (s *MondrianSketch) Update(i int) {
-	rndX := rand.Float64() * float64(s.sourceWidth)
-	rndY := rand.Float64() * float64(s.sourceHeight)
-	r, g, b := util.Rgb255(s.source.At(int(rndX), int(rndY)))
+	destX := s.Rand.Float64() * float64(s.DestWidth)
+	destY := s.Rand.Float64() * float64(s.DestHeight)
+	r, g, b, ok := sourceAt(s.source, destX, destY)
 
-	destX := rndX * float64(s.DestWidth) / float64(s.sourceWidth)
-	destY := rndY * float64(s.DestHeight) / float64(s.sourceHeight)
-
-	size := 0.01*float64(s.sourceWidth) + rand.Float64()*0.15*float64(s.sourceWidth)
+	size := 0.01*float64(s.DestWidth) + s.Rand.Float64()*0.15*float64(s.DestWidth)
+	if !ok {
+		return
+	}
 
 	// black border
 	s.DC.SetRGBA255(0, 0, 0, 255)
@@ -0,0 +1,21 @@
+package sketch
+
+import (
+	"math/rand"
+)
+
+// Random is the random source a sketch draws from
+// Every sketch has its own, so renders running side by side don't take turns on math/rand and a seeded render is
+// repeatable whatever else is running.
+type Random struct {
+	// Rand is left out of json so a request can't set it and it doesn't change a request's hash
+	Rand *rand.Rand `json:"-"`
+}
+
+// randSource returns r, or a new source seeded from math/rand when r is nil
+func randSource(r *rand.Rand) *rand.Rand {
+	if r != nil {
+		return r
+	}
+	return rand.New(rand.NewSource(rand.Int63()))
+}
@@ -0,0 +1,478 @@
+package sketch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math/rand"
+	"reflect"
+	"sort"
+)
+
+// RenderOptions contains the inputs shared by every sketch when rendering without the cli
+type RenderOptions struct {
+	Width      int
+	Height     int
+	Iterations int
+	// Seed makes the render repeatable when it is not zero
+	Seed int64
+	// Source is the image used by sketches that paint from a photo
+	Source image.Image
+	// Context stops the render between iterations, or between steps of a single pass, once it is done, nil renders to the end
+	Context context.Context
+
+	// rng is the render's own random source, made by Render from Seed
+	rng *rand.Rand
+}
+
+// Renderer knows how to build and run one sketch to completion
+type Renderer struct {
+	// Source is true when the sketch needs a source image
+	Source bool
+	// Iterations is the default number of iterations for the sketch
+	Iterations int
+	// Params returns a pointer to the default parameters for a canvas size
+	Params func(width, height int, palette [][3]int) interface{}
+	// Render draws the sketch using parameters from Params
+	Render func(params interface{}, opts RenderOptions) (image.Image, error)
+}
+
+// Renderers contains every sketch by command name
+var Renderers = map[string]Renderer{
+	"anderson": {
+		Iterations: 3,
+		Params: func(width, height int, palette [][3]int) interface{} {
+			return &AndersonParams{
+				DestWidth:      width,
+				DestHeight:     height,
+				LeftEdge:       1,
+				RightEdge:      1,
+				Palette:        palette,
+				WaterScale:     1.4,
+				RippleSpacing:  100,
+				WaveAmplitude:  6,
+				WaveLength:     40,
+				ReflectionBlur: 4,
+			}
+		},
+		Render: func(params interface{}, opts RenderOptions) (image.Image, error) {
+			p := *params.(*AndersonParams)
+			p.Rand = opts.rng
+			p.Iterations = opts.Iterations
+			if err := p.Validate(); err != nil {
+				return nil, err
+			}
+			s := NewAndersonSketch(p)
+			for i := 0; i <= opts.Iterations; i++ {
+				if err := opts.canceled(); err != nil {
+					return nil, err
+				}
+				s.Update(i)
+			}
+			return s.Output(), nil
+		},
+	},
+	"crack": {
+		Iterations: 1000,
+		Params: func(width, height int, palette [][3]int) interface{} {
+			return &CrackParams{
+				DestWidth:       width,
+				DestHeight:      height,
+				CrackLimit:      10,
+				Seeds:           width/10 + height/10,
+				StartingCracks:  2,
+				Palette:         palette,
+				StepLength:      0.42,
+				AngleJitter:     3,
+				RegionLimit:     0.1,
+				SandAlpha:       7,
+				GrainModulation: 0.05,
+				Curve:           "straight",
+				Curvature:       1,
+				NoiseScale:      100,
+				EdgeThreshold:   0.25,
+				EdgeDensity:     0.05,
+				Blend:           "normal",
+				HDROptions:      defaultHDROptions,
+			}
+		},
+		Render: func(params interface{}, opts RenderOptions) (image.Image, error) {
+			p := *params.(*CrackParams)
+			p.Rand = opts.rng
+			if err := p.Validate(); err != nil {
+				return nil, err
+			}
+			s := NewCrackSketch(p)
+			for i := 0; i < opts.Iterations; i++ {
+				if err := opts.canceled(); err != nil {
+					return nil, err
+				}
+				s.Update()
+			}
+			return s.Output(), nil
+		},
+	},
+	"crawl": {
+		Iterations: 100,
+		Params: func(width, height int, palette [][3]int) interface{} {
+			return &CrawlParams{DestWidth: width, DestHeight: height, Count: 3, Start: "center", Collision: "none", MaxCrawlers: 100, Edge: "stop", Field: "none", FieldScale: 200, FieldStrength: 0.8, Blend: "normal"}
+		},
+		Render: func(params interface{}, opts RenderOptions) (image.Image, error) {
+			p := *params.(*CrawlParams)
+			p.Rand = opts.rng
+			p.Iterations = opts.Iterations
+			if p.Field == "image" {
+				p.FieldSource = opts.Source
+			}
+			if err := p.Validate(); err != nil {
+				return nil, err
+			}
+			s := NewCrawlSketch(p)
+			for i := 1; i <= opts.Iterations; i++ {
+				if err := opts.canceled(); err != nil {
+					return nil, err
+				}
+				s.Update(i)
+			}
+			return s.Output(), nil
+		},
+	},
+	"firework": {
+		Iterations: 100,
+		Params: func(width, height int, palette [][3]int) interface{} {
+			return &FireworkParams{
+				DestWidth:  width,
+				DestHeight: height,
+				Shells:     8,
+				Particles:  150,
+				BurstSpeed: float64(height) / 80,
+				Gravity:    float64(height) / 2000,
+				Drag:       0.05,
+				Life:       60,
+				Palette:    palette,
+				Blend:      "add",
+				HDROptions: defaultHDROptions,
+			}
+		},
+		Render: func(params interface{}, opts RenderOptions) (image.Image, error) {
+			p := *params.(*FireworkParams)
+			p.Rand = opts.rng
+			p.Iterations = opts.Iterations
+			if err := p.Validate(); err != nil {
+				return nil, err
+			}
+			s := NewFireworkSketch(p)
+			for i := 0; i <= opts.Iterations; i++ {
+				if err := opts.canceled(); err != nil {
+					return nil, err
+				}
+				s.Update(i)
+			}
+			return s.Output(), nil
+		},
+	},
+	"flip": {
+		Source: true,
+		Params: func(width, height int, palette [][3]int) interface{} {
+			return &flipRenderParams{
+				FlipParams: FlipParams{
+					DestWidth:     width,
+					DestHeight:    height,
+					Tiling:        "diamond",
+					RotateChance:  0.25,
+					Scale:         1.5,
+					HueShift:      180,
+					BorderChance:  0.03,
+					Margin:        1,
+					SourceOptions: defaultSourceOptions,
+				},
+				Divisions: 12,
+			}
+		},
+		Render: func(params interface{}, opts RenderOptions) (image.Image, error) {
+			p := *params.(*flipRenderParams)
+			p.Rand = opts.rng
+			// a tile is at least a pixel tall
+			if p.Divisions < 1 || p.Divisions > opts.Height {
+				return nil, errors.New("divisions must be between 1 and the height of the canvas")
+			}
+			if err := p.Validate(); err != nil {
+				return nil, err
+			}
+			s := NewFlipSketch(opts.Source, p.FlipParams)
+			if err := s.Draw(opts.ctx(), p.Divisions); err != nil {
+				return nil, err
+			}
+			return s.Output(), nil
+		},
+	},
+	"grid": {
+		Source: true,
+		Params: func(width, height int, palette [][3]int) interface{} {
+			return &GridParams{
+				DestWidth:     width,
+				DestHeight:    height,
+				Size:          20,
+				SourceOptions: defaultSourceOptions,
+				Shape:         "circle",
+				Sides:         5,
+				Lattice:       "square",
+				Jitter:        0.3,
+				Vignette:      "none",
+			}
+		},
+		Render: func(params interface{}, opts RenderOptions) (image.Image, error) {
+			p := *params.(*GridParams)
+			p.Rand = opts.rng
+			if err := p.Validate(); err != nil {
+				return nil, err
+			}
+			s := NewGridSketch(opts.Source, p)
+			if err := s.Draw(opts.ctx()); err != nil {
+				return nil, err
+			}
+			return s.Output(), nil
+		},
+	},
+	"growth": {
+		Params: func(width, height int, palette [][3]int) interface{} {
+			return &GrowthParams{DestWidth: width, DestHeight: height, StartingSeeds: 5, Palette: palette}
+		},
+		Render: func(params interface{}, opts RenderOptions) (image.Image, error) {
+			p := *params.(*GrowthParams)
+			p.Rand = opts.rng
+			if err := p.Validate(); err != nil {
+				return nil, err
+			}
+			s := NewGrowthSketch(p)
+			if err := s.Draw(opts.ctx()); err != nil {
+				return nil, err
+			}
+			return s.Output(), nil
+		},
+	},
+	"layer": {
+		Source: true,
+		Params: func(width, height int, palette [][3]int) interface{} {
+			return &LayerParams{
+				DestWidth:              width,
+				DestHeight:             height,
+				PathRatio:              0.5,
+				PathReduction:          0.001,
+				PathMin:                5,
+				PathJitter:             int(0.007 * float64(width)),
+				InitialAlpha:           0.1,
+				AlphaIncrease:          0.006,
+				PathInversionThreshold: 0.05,
+				SourceOptions:          defaultSourceOptions,
+				HDROptions:             defaultHDROptions,
+			}
+		},
+		Render: func(params interface{}, opts RenderOptions) (image.Image, error) {
+			p := *params.(*LayerParams)
+			p.Rand = opts.rng
+			if p.MinEdgeCount > p.MaxEdgeCount {
+				p.MaxEdgeCount = p.MinEdgeCount
+			}
+			if p.PathReduction <= 0 && opts.Iterations == 0 {
+				return nil, errors.New("reduction must be positive when iterations is not set")
+			}
+			if err := p.Validate(); err != nil {
+				return nil, err
+			}
+			s := NewLayerSketch(opts.Source, p)
+			if opts.Iterations == 0 {
+				for s.PathSize >= p.PathMin {
+					if err := opts.canceled(); err != nil {
+						return nil, err
+					}
+					s.Update()
+				}
+			} else {
+				for i := 0; i < opts.Iterations; i++ {
+					if err := opts.canceled(); err != nil {
+						return nil, err
+					}
+					s.Update()
+				}
+			}
+			return s.Output(), nil
+		},
+	},
+	"mondrian": {
+		Source:     true,
+		Iterations: 100,
+		Params: func(width, height int, palette [][3]int) interface{} {
+			return &MondrianParams{DestWidth: width, DestHeight: height, SourceOptions: defaultSourceOptions}
+		},
+		Render: func(params interface{}, opts RenderOptions) (image.Image, error) {
+			p := *params.(*MondrianParams)
+			p.Rand = opts.rng
+			if err := p.SourceOptions.Validate(); err != nil {
+				return nil, err
+			}
+			s := NewMondrianSketch(opts.Source, p)
+			for i := 1; i <= opts.Iterations; i++ {
+				if err := opts.canceled(); err != nil {
+					return nil, err
+				}
+				s.Update(i)
+			}
+			return s.Output(), nil
+		},
+	},
+	"rows": {
+		Source: true,
+		Params: func(width, height int, palette [][3]int) interface{} {
+			return &RowsParams{DestWidth: width, DestHeight: height, Size: 20, SourceOptions: defaultSourceOptions}
+		},
+		Render: func(params interface{}, opts RenderOptions) (image.Image, error) {
+			p := *params.(*RowsParams)
+			if p.Size < 1 {
+				return nil, errors.New("size must be at least 1")
+			}
+			if err := p.SourceOptions.Validate(); err != nil {
+				return nil, err
+			}
+			s := NewRowsSketch(opts.Source, p)
+			if err := s.Draw(opts.ctx()); err != nil {
+				return nil, err
+			}
+			return s.Output(), nil
+		},
+	},
+	"spiral": {
+		Iterations: 200,
+		Params: func(width, height int, palette [][3]int) interface{} {
+			return &SpiralParams{DestWidth: width, DestHeight: height, Beta: 1, Mu: 0.1, Palette: palette, Family: "log", Arms: 1, CenterX: 0.5, CenterY: 0.5, ColorMode: "random", ColorSpace: "lab"}
+		},
+		Render: func(params interface{}, opts RenderOptions) (image.Image, error) {
+			p := *params.(*SpiralParams)
+			p.Rand = opts.rng
+			p.Iterations = opts.Iterations
+			if p.ColorMode == "image" {
+				p.ColorSource = opts.Source
+			}
+			if err := p.Validate(); err != nil {
+				return nil, err
+			}
+			s := NewSpiralSketch(p)
+			for i := 1; i <= opts.Iterations; i++ {
+				if err := opts.canceled(); err != nil {
+					return nil, err
+				}
+				s.Update(i)
+			}
+			return s.Output(), nil
+		},
+	},
+	"stack": {
+		Source:     true,
+		Iterations: 10,
+		Params: func(width, height int, palette [][3]int) interface{} {
+			return &StackParams{DestWidth: width, DestHeight: height, SourceOptions: defaultSourceOptions}
+		},
+		Render: func(params interface{}, opts RenderOptions) (image.Image, error) {
+			p := *params.(*StackParams)
+			if err := p.SourceOptions.Validate(); err != nil {
+				return nil, err
+			}
+			s := NewStackSketch(opts.Source, p)
+			for i := 1; i <= opts.Iterations; i++ {
+				if err := opts.canceled(); err != nil {
+					return nil, err
+				}
+				s.Update(i)
+			}
+			return s.Output(), nil
+		},
+	},
+	"sun": {
+		Params: func(width, height int, palette [][3]int) interface{} {
+			return &SunParams{DestWidth: width, DestHeight: height, SunRadius: 50, LineWidth: 5}
+		},
+		Render: func(params interface{}, opts RenderOptions) (image.Image, error) {
+			p := *params.(*SunParams)
+			p.Rand = opts.rng
+			if p.LineWidth <= 0 {
+				return nil, errors.New("line width must be positive")
+			}
+			s := NewSunSketch(p)
+			if err := s.Draw(opts.ctx()); err != nil {
+				return nil, err
+			}
+			return s.Output(), nil
+		},
+	},
+}
+
+// flipRenderParams adds the flip command's divisions, which are passed to Draw rather than held in FlipParams
+type flipRenderParams struct {
+	FlipParams
+	Divisions int
+}
+
+// defaultSourceOptions fills the canvas with the middle of the source
+var defaultSourceOptions = SourceOptions{Fit: "cover", Anchor: "center"}
+
+// SketchNames lists the registered sketches in order
+func SketchNames() []string {
+	names := make([]string, 0, len(Renderers))
+	for name := range Renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render runs a registered sketch with params taken from its Renderer's Params
+func Render(name string, params interface{}, opts RenderOptions) (image.Image, error) {
+	r, ok := Renderers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown sketch %q", name)
+	}
+	if opts.Width <= 0 || opts.Height <= 0 {
+		return nil, errors.New("width and height must be positive")
+	}
+	if r.Source && opts.Source == nil {
+		return nil, fmt.Errorf("sketch %q needs a source image", name)
+	}
+	if err := opts.canceled(); err != nil {
+		return nil, err
+	}
+	// the canvas size always comes from the options, whatever the params say
+	setDimensions(params, opts.Width, opts.Height)
+
+	// a seed gives the same random source, and so the same image, every time
+	seed := opts.Seed
+	if seed == 0 {
+		seed = rand.Int63()
+	}
+	opts.rng = rand.New(rand.NewSource(seed))
+
+	return r.Render(params, opts)
+}
+
+// ctx is the render's context, a render without one is never canceled
+func (opts RenderOptions) ctx() context.Context {
+	if opts.Context == nil {
+		return context.Background()
+	}
+	return opts.Context
+}
+
+// canceled is the context's error once the render should stop
+func (opts RenderOptions) canceled() error {
+	return opts.ctx().Err()
+}
+
+func setDimensions(params interface{}, width, height int) {
+	v := reflect.ValueOf(params).Elem()
+	if f := v.FieldByName("DestWidth"); f.IsValid() && f.CanSet() {
+		f.SetInt(int64(width))
+	}
+	if f := v.FieldByName("DestHeight"); f.IsValid() && f.CanSet() {
+		f.SetInt(int64(height))
+	}
+}
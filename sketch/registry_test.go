@@ -0,0 +1,67 @@
+package sketch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/png"
+	"sync"
+	"testing"
+)
+
+func TestRenderStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := Renderers["crack"]
+	opts := RenderOptions{Width: 40, Height: 30, Iterations: r.Iterations, Seed: 1, Context: ctx}
+	if _, err := Render("crack", r.Params(40, 30, nil), opts); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRenderersStopPartWayWhenCanceled(t *testing.T) {
+	// Render itself gives up before starting, so call each sketch's renderer to see that it stops on its own
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, name := range SketchNames() {
+		r := Renderers[name]
+		// big enough that every sketch draws something
+		opts := RenderOptions{Width: 200, Height: 150, Iterations: r.Iterations, Context: ctx}
+		if r.Source {
+			opts.Source = testSource(200, 150)
+		}
+		if _, err := r.Render(r.Params(200, 150, nil), opts); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: got %v, want %v", name, err, context.Canceled)
+		}
+	}
+}
+
+func TestSeededRendersSideBySide(t *testing.T) {
+	// renders running at the same time each keep to their own seed
+	encode := func(name string) []byte {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, renderGolden(t, name, 48, 32)); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	want := encode("crack")
+
+	var wg sync.WaitGroup
+	got := make([][]byte, 4)
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i] = encode("crack")
+			// an unseeded render draws from its own source too
+			Render("sun", Renderers["sun"].Params(48, 32, nil), RenderOptions{Width: 48, Height: 32})
+		}(i)
+	}
+	wg.Wait()
+	for i, g := range got {
+		if !bytes.Equal(g, want) {
+			t.Errorf("render %d differs from the same seed rendered alone", i)
+		}
+	}
+}
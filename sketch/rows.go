@@ -1,12 +1,12 @@
 package sketch
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
 
 	"github.com/fogleman/gg"
-	"gitlab.com/ericworkman/generative/util"
 )
 
 // RowsParams contains externally-provided parameters
@@ -16,15 +16,14 @@ type RowsParams struct {
 	DestHeight int
 	Vignette   bool
 	Size       float64
+	SourceOptions
 }
 
 // RowsSketch wraps all the components needed to draw the sketch
 type RowsSketch struct {
 	RowsParams
-	DC           *gg.Context
-	source       image.Image
-	sourceWidth  int
-	sourceHeight int
+	DC     *gg.Context
+	source *image.RGBA
 }
 
 // NewRowsSketch initializes the canvas and RowsSketch
@@ -32,9 +31,7 @@ func NewRowsSketch(source image.Image, params RowsParams) *RowsSketch {
 	fmt.Println("Starting Sketch")
 
 	s := &RowsSketch{RowsParams: params}
-	bounds := source.Bounds()
-	s.sourceWidth, s.sourceHeight = bounds.Max.X, bounds.Max.Y
-	s.source = source
+	s.source = prepareSource(source, s.DestWidth, s.DestHeight, s.SourceOptions)
 
 	// canvas is a gg image context and contains what gets drawn to the screen
 	canvas := gg.NewContext(s.DestWidth, s.DestHeight)
@@ -50,17 +47,23 @@ func NewRowsSketch(source image.Image, params RowsParams) *RowsSketch {
 	return s
 }
 
-// Draw completes the drawing
-func (s *RowsSketch) Draw() {
+// Draw completes the drawing, stopping between columns once ctx is done
+func (s *RowsSketch) Draw(ctx context.Context) error {
 	alpha := 200.0
 
 	spacing := s.Size
 	iteration := 0.0
-	for x := spacing; x < (float64(s.sourceWidth)-spacing)/2; x += spacing {
+	for x := spacing; x < (float64(s.DestWidth)-spacing)/2; x += spacing {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		iteration += 2.0
 		endx := float64(s.DestWidth) - iteration*spacing
-		for y := spacing; y < float64(s.sourceHeight)-spacing; y += spacing {
-			r, g, b := util.Rgb255(s.source.At(int(x), int(y)))
+		for y := spacing; y < float64(s.DestHeight)-spacing; y += spacing {
+			r, g, b, ok := sourceAt(s.source, x, y)
+			if !ok {
+				continue
+			}
 			s.DC.SetRGBA255(r, g, b, int(alpha))
 			s.DC.DrawRoundedRectangle(x, y, endx, spacing, spacing/4)
 			//s.DC.DrawRectangle(x, y, endx, spacing)
@@ -68,7 +71,7 @@ func (s *RowsSketch) Draw() {
 			s.DC.Stroke()
 		}
 	}
-
+	return nil
 }
 
 // Output produces an image output of the current state of the sketch
@@ -0,0 +1,173 @@
+package sketch
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"gitlab.com/ericworkman/generative/util"
+	"golang.org/x/image/draw"
+)
+
+// SourceFits are the ways a source image can be fitted to the canvas
+var SourceFits = []string{"cover", "contain", "stretch", "none"}
+
+// SourceAnchors are the points a source image can be held to when it doesn't fit the canvas exactly
+var SourceAnchors = []string{"center", "top", "bottom", "left", "right", "top-left", "top-right", "bottom-left", "bottom-right"}
+
+// SourceOptions controls how a source image is prepared before a sketch paints from it
+type SourceOptions struct {
+	// Fit is how the source is fitted to the canvas, one of SourceFits
+	// cover fills the canvas and crops the overhang, contain fits the whole source leaving the rest of the canvas empty,
+	// stretch ignores the source's shape, and none keeps the source's size.
+	Fit string
+	// Anchor is the part of the source kept when it's cropped, or the part of the canvas it sits in when it doesn't fill it
+	Anchor string
+	// Crop trims fractions of the source from the left, top, right and bottom before it's fitted
+	Crop [4]float64
+}
+
+// Validate rejects unknown fits and anchors, and crops that are negative or leave nothing
+func (o SourceOptions) Validate() error {
+	if err := checkChoice("fit", o.Fit, SourceFits); err != nil {
+		return err
+	}
+	if err := checkChoice("anchor", o.Anchor, SourceAnchors); err != nil {
+		return err
+	}
+	for _, c := range o.Crop {
+		if c < 0 {
+			return errors.New("crop can't be negative")
+		}
+	}
+	if o.Crop[0]+o.Crop[2] >= 1 || o.Crop[1]+o.Crop[3] >= 1 {
+		return errors.New("crop must leave some of the source")
+	}
+	return nil
+}
+
+// scaledAt samples a source image at canvas coordinates, stretching the source over the whole canvas
+func scaledAt(src image.Image, x, y float64, width, height int) color.Color {
+	b := src.Bounds()
+	sx := b.Min.X + int(x*float64(b.Dx())/float64(width))
+	sy := b.Min.Y + int(y*float64(b.Dy())/float64(height))
+	if sx >= b.Max.X {
+		sx = b.Max.X - 1
+	}
+	if sy >= b.Max.Y {
+		sy = b.Max.Y - 1
+	}
+	return src.At(sx, sy)
+}
+
+// luminance is the perceived brightness of a color between 0 and 1
+func luminance(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 65535
+}
+
+// luminanceGrid samples the luminance of a source image at every canvas pixel
+func luminanceGrid(src image.Image, width, height int) []float64 {
+	lum := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			lum[y*width+x] = luminance(scaledAt(src, float64(x)+0.5, float64(y)+0.5, width, height))
+		}
+	}
+	return lum
+}
+
+// sobel returns the luminance gradient at a canvas pixel, clamping at the edges
+func sobel(lum []float64, width, height, x, y int) (gx, gy float64) {
+	at := func(x, y int) float64 {
+		if x < 0 {
+			x = 0
+		} else if x >= width {
+			x = width - 1
+		}
+		if y < 0 {
+			y = 0
+		} else if y >= height {
+			y = height - 1
+		}
+		return lum[y*width+x]
+	}
+	gx = at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x-1, y) - at(x-1, y+1)
+	gy = at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1)
+	return gx, gy
+}
+
+// maxSobel is the largest gradient magnitude sobel can return for luminance between 0 and 1
+var maxSobel = 4 * math.Sqrt2
+
+// anchorPoint is where an anchor sits, from 0 at the left or top to 1 at the right or bottom
+func anchorPoint(anchor string) (x, y float64) {
+	x, y = 0.5, 0.5
+	if strings.Contains(anchor, "left") {
+		x = 0
+	} else if strings.Contains(anchor, "right") {
+		x = 1
+	}
+	if strings.Contains(anchor, "top") {
+		y = 0
+	} else if strings.Contains(anchor, "bottom") {
+		y = 1
+	}
+	return x, y
+}
+
+// prepareSource crops and resamples a source image onto a width by height canvas, so sketches can sample it in canvas
+// coordinates. Parts of the canvas the source doesn't cover are left transparent.
+func prepareSource(src image.Image, width, height int, opts SourceOptions) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sr := src.Bounds()
+	fw, fh := float64(sr.Dx()), float64(sr.Dy())
+	sr = image.Rect(
+		sr.Min.X+int(opts.Crop[0]*fw), sr.Min.Y+int(opts.Crop[1]*fh),
+		sr.Max.X-int(opts.Crop[2]*fw), sr.Max.Y-int(opts.Crop[3]*fh),
+	)
+	if sr.Empty() {
+		return dst
+	}
+
+	ax, ay := anchorPoint(opts.Anchor)
+	dr := dst.Bounds()
+	// compare aspect ratios by cross multiplying, a wider source has sw*height > width*sh
+	sw, sh := sr.Dx(), sr.Dy()
+	switch opts.Fit {
+	case "cover":
+		if sw*height > width*sh {
+			w := util.MaxInt(1, sh*width/height)
+			sr.Min.X += int(ax * float64(sw-w))
+			sr.Max.X = sr.Min.X + w
+		} else {
+			h := util.MaxInt(1, sw*height/width)
+			sr.Min.Y += int(ay * float64(sh-h))
+			sr.Max.Y = sr.Min.Y + h
+		}
+	case "contain":
+		if sw*height > width*sh {
+			h := sh * width / sw
+			dr.Min.Y = int(ay * float64(height-h))
+			dr.Max.Y = dr.Min.Y + h
+		} else {
+			w := sw * height / sh
+			dr.Min.X = int(ax * float64(width-w))
+			dr.Max.X = dr.Min.X + w
+		}
+	case "none":
+		dp := image.Pt(int(ax*float64(width-sw)), int(ay*float64(height-sh)))
+		draw.Copy(dst, dp, src, sr, draw.Src, nil)
+		return dst
+	}
+	draw.CatmullRom.Scale(dst, dr, src, sr, draw.Src, nil)
+	return dst
+}
+
+// sourceAt reads the 0-255 color of a prepared source at a canvas point, ok is false where the source doesn't cover the canvas
+func sourceAt(src *image.RGBA, x, y float64) (r, g, b int, ok bool) {
+	c := src.RGBAAt(int(math.Floor(x)), int(math.Floor(y)))
+	return int(c.R), int(c.G), int(c.B), c.A > 0
+}
@@ -0,0 +1,51 @@
+package sketch
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// halves is a source that is red on the left half and blue on the right
+func halves(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x < width/2 {
+				img.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				img.SetRGBA(x, y, color.RGBA{0, 0, 255, 255})
+			}
+		}
+	}
+	return img
+}
+
+func TestPrepareSource(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	empty := color.RGBA{}
+	for name, c := range map[string]struct {
+		opts  SourceOptions
+		x, y  int
+		color color.RGBA
+	}{
+		"cover keeps the middle":    {SourceOptions{Fit: "cover", Anchor: "center"}, 10, 50, red},
+		"cover anchored right":      {SourceOptions{Fit: "cover", Anchor: "right"}, 10, 50, blue},
+		"contain leaves bars":       {SourceOptions{Fit: "contain", Anchor: "center"}, 50, 10, empty},
+		"contain anchored top":      {SourceOptions{Fit: "contain", Anchor: "top"}, 90, 10, blue},
+		"stretch fills":             {SourceOptions{Fit: "stretch", Anchor: "center"}, 90, 90, blue},
+		"none keeps the size":       {SourceOptions{Fit: "none", Anchor: "top-left"}, 90, 40, red},
+		"none is empty past source": {SourceOptions{Fit: "none", Anchor: "top-left"}, 90, 60, empty},
+		"crop trims the left":       {SourceOptions{Fit: "stretch", Anchor: "center", Crop: [4]float64{0.5, 0, 0, 0}}, 10, 10, blue},
+	} {
+		src := halves(400, 50)
+		if c.opts.Fit != "none" {
+			src = halves(200, 100)
+		}
+		got := prepareSource(src, 100, 100, c.opts).RGBAAt(c.x, c.y)
+		if got != c.color {
+			t.Errorf("%s: got %v at %d, %d, want %v", name, got, c.x, c.y, c.color)
+		}
+	}
+}
@@ -1,13 +1,15 @@
 package sketch
 
 import (
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"math"
-	"math/rand"
 
 	"github.com/fogleman/gg"
+	"github.com/teacat/noire"
+	"gitlab.com/ericworkman/generative/util"
 )
 
 var (
@@ -39,6 +41,62 @@ type SpiralParams struct {
 	// see https://www.wolframalpha.com/input/?i=parametric+plot+%281%2Be%5E%280.1+t%29sin+t%2C+1%2Be%5E%280.1t%29cos+t%29+for+t%3D-20+to+10
 	Beta float64
 	Mu   float64
+	// Palette replaces the default dot colors when it is not empty
+	Palette [][3]int
+	// Family is the kind of spiral: log, archimedean, fermat or hyperbolic
+	// log grows as Beta*e^(Mu*t) from the center. The others are sized so that with a Beta of 1 the last dot reaches
+	// the farthest corner: archimedean grows evenly with the angle, fermat with its square root and hyperbolic shrinks towards the center.
+	Family string
+	// Arms is the number of copies of the spiral, turned evenly around the center
+	Arms int
+	// Step is the angle in radians between dots, 0 for one radian or the golden angle for fermat
+	Step float64
+	// CenterX and CenterY place the center of the spiral as a fraction of the canvas
+	CenterX float64
+	CenterY float64
+	// ColorMode picks each dot's color: random from the palette, or along a gradient through the palette by
+	// curve (how far along the spiral), radius or angle, or image to take the color under the dot from ColorSource
+	ColorMode string
+	// ColorSpace is where gradient colors are mixed: hsl turns through hues, lab keeps steps looking even
+	ColorSpace string
+	// ColorSource is the image sampled by the image color mode
+	ColorSource image.Image `json:"-"`
+	Random
+}
+
+// SpiralColorModes are the accepted values of SpiralParams.ColorMode
+var SpiralColorModes = []string{"random", "curve", "radius", "angle", "image"}
+
+// SpiralColorSpaces are the accepted values of SpiralParams.ColorSpace
+var SpiralColorSpaces = []string{"hsl", "lab"}
+
+// SpiralFamilies are the accepted values of SpiralParams.Family
+var SpiralFamilies = []string{"log", "archimedean", "fermat", "hyperbolic"}
+
+// goldenAngle is the turn between seeds in a sunflower head, which packs them without gaps or lines
+var goldenAngle = math.Pi * (3 - math.Sqrt(5))
+
+// Validate rejects unknown families and color options, a spiral without arms or with more arms than pixels, and the image mode without an image
+func (p SpiralParams) Validate() error {
+	if err := checkOptionalChoice("family", p.Family, SpiralFamilies); err != nil {
+		return err
+	}
+	if p.Arms < 1 || p.Arms > p.DestWidth*p.DestHeight {
+		return fmt.Errorf("arms must be between 1 and %d, one for each pixel", p.DestWidth*p.DestHeight)
+	}
+	if p.Step < 0 {
+		return errors.New("step can't be negative")
+	}
+	if err := checkOptionalChoice("color mode", p.ColorMode, SpiralColorModes); err != nil {
+		return err
+	}
+	if err := checkOptionalChoice("color space", p.ColorSpace, SpiralColorSpaces); err != nil {
+		return err
+	}
+	if p.ColorMode == "image" && p.ColorSource == nil {
+		return errors.New("the image color mode needs a source image")
+	}
+	return nil
 }
 
 // SpiralSketch wraps all the components needed to draw the spiral sketch
@@ -48,6 +106,9 @@ type SpiralSketch struct {
 	currentR float64
 	centerX  float64
 	centerY  float64
+	step     float64
+	// reach is the distance from the center to the farthest corner
+	reach float64
 }
 
 // NewSpiralSketch initializes the canvas and SpiralSketch
@@ -56,9 +117,21 @@ func NewSpiralSketch(params SpiralParams) *SpiralSketch {
 
 	s := &SpiralSketch{SpiralParams: params,
 		currentR: 2.0,
-		centerX:  float64(params.DestWidth) / 2.0,
-		centerY:  float64(params.DestHeight) / 2.0,
+		centerX:  float64(params.DestWidth) * params.CenterX,
+		centerY:  float64(params.DestHeight) * params.CenterY,
+		step:     params.Step,
+	}
+	s.Rand = randSource(s.Rand)
+	if s.step == 0 {
+		s.step = 1
+		if s.Family == "fermat" {
+			s.step = goldenAngle
+		}
 	}
+	s.reach = math.Max(
+		math.Hypot(math.Max(s.centerX, float64(s.DestWidth)-s.centerX), math.Max(s.centerY, float64(s.DestHeight)-s.centerY)),
+		1,
+	)
 
 	// canvas is a gg image context and contains what gets drawn to the screen
 	canvas := gg.NewContext(s.DestWidth, s.DestHeight)
@@ -76,20 +149,93 @@ func (s *SpiralSketch) Output() image.Image {
 	return s.DC.Image()
 }
 
+// radius is the distance of the dot at angle t from the center
+func (s *SpiralSketch) radius(t float64) float64 {
+	last := math.Max(float64(s.Iterations)*s.step, s.step)
+	switch s.Family {
+	case "archimedean":
+		return s.Beta * s.reach * t / last
+	case "fermat":
+		return s.Beta * s.reach * math.Sqrt(t/last)
+	case "hyperbolic":
+		return s.Beta * s.reach * s.step / t
+	}
+	return s.Beta * math.Exp(t*s.Mu)
+}
+
 // Update makes a logical step into generation
 func (s *SpiralSketch) Update(i int) {
-	j := float64(i)
-	x := s.centerX + (s.Beta * math.Exp(j*s.Mu) * math.Cos(j))
-	y := s.centerY + (s.Beta * math.Exp(j*s.Mu) * math.Sin(j))
+	t := float64(i) * s.step
+	r := s.radius(t)
 
-	color := spiralColors[rand.Intn(len(spiralColors))]
-	s.DC.SetRGBA255(color[0], color[1], color[2], 255.0)
 	// logistic growth of radius, barely noticable in practice I think
 	s.currentR += 0.006 * float64(i) * float64(s.Iterations-i) / float64(s.Iterations)
-	if x >= 0.0 && y >= 0.0 && x <= float64(s.DestHeight)*1.5 && y <= float64(s.DestHeight)*1.5 {
-		s.DC.DrawCircle(x, y, s.currentR)
+
+	for arm := 0; arm < s.Arms; arm++ {
+		a := t + 2*math.Pi*float64(arm)/float64(s.Arms)
+		x := s.centerX + r*math.Cos(a)
+		y := s.centerY + r*math.Sin(a)
+
+		color := s.dotColor(i, x, y, r, a)
+		s.DC.SetRGBA255(color[0], color[1], color[2], 255.0)
+		// skip dots that wouldn't touch the canvas
+		if x >= -s.currentR && y >= -s.currentR && x <= float64(s.DestWidth)+s.currentR && y <= float64(s.DestHeight)+s.currentR {
+			s.DC.DrawCircle(x, y, s.currentR)
+		}
+		s.DC.FillPreserve()
+
+		s.DC.Stroke()
+	}
+}
+
+// dotColor picks the color of a dot at x, y, which is r from the center at angle a
+func (s *SpiralSketch) dotColor(i int, x, y, r, a float64) [3]int {
+	stops := spiralColors[:]
+	if len(s.Palette) > 0 {
+		stops = s.Palette
+	}
+	switch s.ColorMode {
+	case "curve":
+		return gradientColor(stops, float64(i)/math.Max(float64(s.Iterations), 1), s.ColorSpace)
+	case "radius":
+		return gradientColor(stops, r/s.reach, s.ColorSpace)
+	case "angle":
+		return gradientColor(stops, math.Mod(math.Mod(a, 2*math.Pi)+2*math.Pi, 2*math.Pi)/(2*math.Pi), s.ColorSpace)
+	case "image":
+		x = math.Max(0, math.Min(x, float64(s.DestWidth-1)))
+		y = math.Max(0, math.Min(y, float64(s.DestHeight-1)))
+		cr, cg, cb := util.Rgb255(scaledAt(s.ColorSource, x, y, s.DestWidth, s.DestHeight))
+		return [3]int{cr, cg, cb}
 	}
-	s.DC.FillPreserve()
+	return stops[s.Rand.Intn(len(stops))]
+}
 
-	s.DC.Stroke()
+// gradientColor is the color at t, from 0 to 1, along a gradient through evenly spaced stops
+// Neighboring stops are mixed in the given color space, hsl or lab.
+func gradientColor(stops [][3]int, t float64, space string) [3]int {
+	if len(stops) == 1 {
+		return stops[0]
+	}
+	t = math.Max(0, math.Min(t, 1)) * float64(len(stops)-1)
+	k := int(t)
+	if k >= len(stops)-1 {
+		return stops[len(stops)-1]
+	}
+	f := t - float64(k)
+	from, to := stops[k], stops[k+1]
+
+	var r, g, b float64
+	if space == "hsl" {
+		h1, s1, l1 := noire.RGBToHSL(float64(from[0]), float64(from[1]), float64(from[2]))
+		h2, s2, l2 := noire.RGBToHSL(float64(to[0]), float64(to[1]), float64(to[2]))
+		// turn the short way around the hue circle
+		dh := math.Mod(h2-h1+540, 360) - 180
+		h := math.Mod(h1+dh*f+360, 360)
+		r, g, b = noire.HSLToRGB(h, s1+(s2-s1)*f, l1+(l2-l1)*f)
+	} else {
+		l1, a1, b1 := util.RGBToLab(float64(from[0]), float64(from[1]), float64(from[2]))
+		l2, a2, b2 := util.RGBToLab(float64(to[0]), float64(to[1]), float64(to[2]))
+		r, g, b = util.LabToRGB(l1+(l2-l1)*f, a1+(a2-a1)*f, b1+(b2-b1)*f)
+	}
+	return [3]int{int(math.Round(r)), int(math.Round(g)), int(math.Round(b))}
 }
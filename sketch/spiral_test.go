@@ -0,0 +1,57 @@
+package sketch
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestSpiralFillsWideCanvas(t *testing.T) {
+	// hyperbolic spirals shrink inwards, only their first few dots are far from the center
+	for _, family := range []string{"log", "archimedean", "fermat"} {
+		s := NewSpiralSketch(SpiralParams{DestWidth: 300, DestHeight: 60, Iterations: 200, Beta: 1, Mu: 0.1, Family: family, Arms: 2, CenterX: 0.5, CenterY: 0.5})
+		for i := 1; i <= 200; i++ {
+			s.Update(i)
+		}
+		img := s.Output()
+		painted := false
+		for x := 240; x < 300 && !painted; x++ {
+			for y := 0; y < 60 && !painted; y++ {
+				painted = img.At(x, y) != color.RGBA{255, 255, 255, 255}
+			}
+		}
+		if !painted {
+			t.Errorf("%s: nothing drawn in the right fifth of the canvas", family)
+		}
+	}
+}
+
+func TestGradientColor(t *testing.T) {
+	stops := [][3]int{{255, 0, 0}, {0, 0, 255}}
+	for _, space := range SpiralColorSpaces {
+		if got := gradientColor(stops, 0, space); got != stops[0] {
+			t.Errorf("%s: start is %v", space, got)
+		}
+		if got := gradientColor(stops, 1, space); got != stops[1] {
+			t.Errorf("%s: end is %v", space, got)
+		}
+	}
+	// red to blue the short way round the hue circle passes magenta
+	if got := gradientColor(stops, 0.5, "hsl"); got != [3]int{255, 0, 255} {
+		t.Errorf("hsl midpoint is %v", got)
+	}
+	// lab is even in lightness, so halfway from black to white is darker than halfway in rgb
+	if got := gradientColor([][3]int{{0, 0, 0}, {255, 255, 255}}, 0.5, "lab"); got[0] < 115 || got[0] > 122 || got[0] != got[1] || got[1] != got[2] {
+		t.Errorf("lab midpoint is %v", got)
+	}
+}
+
+func TestSpiralImageColors(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.RGBA{10, 200, 30, 255}), image.Point{}, draw.Src)
+	s := NewSpiralSketch(SpiralParams{DestWidth: 100, DestHeight: 100, Iterations: 20, Beta: 1, Mu: 0.1, Family: "log", Arms: 1, CenterX: 0.5, CenterY: 0.5, ColorMode: "image", ColorSource: src})
+	if got := s.dotColor(1, 50, 50, 0, 0); got != [3]int{10, 200, 30} {
+		t.Errorf("dot color is %v", got)
+	}
+}
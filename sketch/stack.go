@@ -6,22 +6,20 @@ import (
 	"image/color"
 
 	"github.com/fogleman/gg"
-	"gitlab.com/ericworkman/generative/util"
 )
 
 // StackParams contains user input
 type StackParams struct {
 	DestWidth  int
 	DestHeight int
+	SourceOptions
 }
 
 // StackSketch is the canvas and grid wrapper
 type StackSketch struct {
 	StackParams
-	source       image.Image
-	DC           *gg.Context
-	sourceWidth  int
-	sourceHeight int
+	source *image.RGBA
+	DC     *gg.Context
 }
 
 // NewStackSketch creates a stack sketch
@@ -29,9 +27,7 @@ func NewStackSketch(source image.Image, params StackParams) *StackSketch {
 	fmt.Println("Starting Sketch")
 
 	s := &StackSketch{StackParams: params}
-	bounds := source.Bounds()
-	s.sourceWidth, s.sourceHeight = bounds.Max.X, bounds.Max.Y
-	s.source = source
+	s.source = prepareSource(source, s.DestWidth, s.DestHeight, s.SourceOptions)
 
 	// canvas is a gg image context and contains what gets drawn to the screen
 	canvas := gg.NewContext(s.DestWidth, s.DestHeight)
@@ -57,7 +53,10 @@ func (s *StackSketch) Update(i int) {
 
 	for x := 0.0; x < float64(s.DestWidth); x += limitX {
 		for y := 0.0; y < float64(s.DestHeight); y += limitY {
-			r, g, b := util.Rgb255(s.source.At(int(x+limitX/2), int(y+limitY/2)))
+			r, g, b, ok := sourceAt(s.source, x+limitX/2, y+limitY/2)
+			if !ok {
+				continue
+			}
 			s.DC.SetRGBA255(r, g, b, 255/i)
 			s.DC.DrawEllipse(x+limitX/2, y+limitY/2, limitX/2, limitY/2)
 			s.DC.FillPreserve()
@@ -1,11 +1,11 @@
 package sketch
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
 	"math"
-	"math/rand"
 
 	"github.com/fogleman/gg"
 	"github.com/teacat/noire"
@@ -25,6 +25,7 @@ type SunParams struct {
 	DestHeight int
 	SunRadius  float64
 	LineWidth  float64
+	Random
 }
 
 // SunSketch wraps all the components needed to draw the sketch
@@ -38,6 +39,7 @@ func NewSunSketch(params SunParams) *SunSketch {
 	fmt.Println("Starting Sketch")
 
 	s := &SunSketch{SunParams: params}
+	s.Rand = randSource(s.Rand)
 
 	// canvas is a gg image context and contains what gets drawn to the screen
 	canvas := gg.NewContext(s.DestWidth, s.DestHeight)
@@ -58,13 +60,13 @@ func (s *SunSketch) Output() image.Image {
 	return s.DC.Image()
 }
 
-// Draw completes the drawing
-func (s *SunSketch) Draw() {
+// Draw completes the drawing, stopping between rings once ctx is done
+func (s *SunSketch) Draw(ctx context.Context) error {
 	x := float64(s.DestWidth / 2)
 	y := float64(s.DestHeight / 2)
 
 	sun := noire.NewRGB(233, 168, 6)
-	sun = sun.Tint(util.RandFloat64RangeFrom(-0.2, 0.2))
+	sun = sun.Tint(util.RandFloat64RangeFrom(s.Rand, -0.2, 0.2))
 	sr, sg, sb := sun.RGB()
 	s.DC.SetRGB255(int(sr), int(sg), int(sb))
 	s.DC.DrawCircle(x, y, s.SunRadius)
@@ -74,7 +76,10 @@ func (s *SunSketch) Draw() {
 	skyColor := noire.NewRGB(29, 103, 131)
 
 	for r := s.SunRadius + 1.5*s.LineWidth; r <= math.Sqrt((x*x)+(y*y)); r += (s.LineWidth * 2) {
-		offset := util.RandFloat64RangeFrom(0, 1.0)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		offset := util.RandFloat64RangeFrom(s.Rand, 0, 1.0)
 		start := 0.0
 		distance := 0.0
 		end := 0.0
@@ -82,15 +87,15 @@ func (s *SunSketch) Draw() {
 
 		for i := offset; i < 1.0+offset; i = end {
 			start = i
-			distance = util.RandFloat64RangeFrom(i, util.MinFloat64(1.0+offset-i, 0.45))
+			distance = util.RandFloat64RangeFrom(s.Rand, i, util.MinFloat64(1.0+offset-i, 0.45))
 			end = util.MinFloat64(start+distance, 1.0+offset)
 
 			chosen := skyColor
-			chance := rand.Intn(100)
+			chance := s.Rand.Intn(100)
 			if chance < 50 {
-				chosen = chosen.Tint(util.RandFloat64RangeFrom(0, 0.4))
+				chosen = chosen.Tint(util.RandFloat64RangeFrom(s.Rand, 0, 0.4))
 			} else {
-				chosen = chosen.Shade(util.RandFloat64RangeFrom(0, 0.25))
+				chosen = chosen.Shade(util.RandFloat64RangeFrom(s.Rand, 0, 0.25))
 			}
 			re, g, b := chosen.RGB()
 
@@ -101,5 +106,5 @@ func (s *SunSketch) Draw() {
 		}
 
 	}
-
+	return nil
 }
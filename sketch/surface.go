@@ -0,0 +1,37 @@
+package sketch
+
+import (
+	"errors"
+
+	"gitlab.com/ericworkman/generative/blend"
+)
+
+// HDROptions controls the float canvas a blending sketch can accumulate on
+type HDROptions struct {
+	// HDR accumulates on a float canvas, so faint strokes and bright overlaps both keep their detail
+	HDR bool
+	// Exposure brightens an HDR canvas by powers of two at output, negative values darken it
+	Exposure float64
+	// Gamma above 1 lifts the shadows of an HDR canvas at output
+	Gamma float64
+}
+
+// Validate rejects a gamma that isn't positive when the canvas is HDR
+func (o HDROptions) Validate() error {
+	if o.HDR && o.Gamma <= 0 {
+		return errors.New("gamma must be positive")
+	}
+	return nil
+}
+
+// defaultHDROptions keep the 8-bit canvas, with a gamma that's ready if HDR is switched on
+var defaultHDROptions = HDROptions{Gamma: 1}
+
+// newSurface creates the canvas for a sketch that blends its strokes
+// HDR keeps light adding up past white, and develops it with the exposure and gamma at output.
+func newSurface(width, height int, background [3]int, o HDROptions) blend.Surface {
+	if o.HDR {
+		return blend.NewHDRBuffer(width, height, background, o.Exposure, o.Gamma)
+	}
+	return blend.NewBuffer(width, height, background)
+}
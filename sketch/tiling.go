@@ -0,0 +1,315 @@
+package sketch
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/fogleman/gg"
+)
+
+// tile is one cell of a tiling, a convex polygon around a center
+type tile struct {
+	x, y   float64
+	points []gg.Point
+}
+
+// bounds is the smallest rectangle holding the tile
+func (t tile) bounds() (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, p := range t.points {
+		minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+		minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+	}
+	return
+}
+
+// regularPolygon places the corners of a regular polygon the same way gg's DrawRegularPolygon does
+func regularPolygon(n int, x, y, r, rotation float64) []gg.Point {
+	angle := 2 * math.Pi / float64(n)
+	rotation -= math.Pi / 2
+	if n%2 == 0 {
+		rotation += angle / 2
+	}
+	points := make([]gg.Point, n)
+	for i := range points {
+		a := rotation + angle*float64(i)
+		points[i] = gg.Point{X: x + r*math.Cos(a), Y: y + r*math.Sin(a)}
+	}
+	return points
+}
+
+// tiling covers a width by height canvas with tiles about size across, dropping tiles that miss the canvas
+func tiling(kind string, width, height int, size float64, r *rand.Rand) []tile {
+	var tiles []tile
+	switch kind {
+	case "square":
+		tiles = squareTiles(width, height, size)
+	case "hex":
+		tiles = hexTiles(width, height, size)
+	case "triangle":
+		tiles = triangleTiles(width, height, size)
+	case "penrose":
+		tiles = penroseTiles(width, height, size)
+	case "voronoi":
+		tiles = voronoiTiles(width, height, size, r)
+	default:
+		tiles = diamondTiles(width, height, size)
+	}
+
+	kept := tiles[:0]
+	for _, t := range tiles {
+		minX, minY, maxX, maxY := t.bounds()
+		if maxX > 0 && maxY > 0 && minX < float64(width) && minY < float64(height) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// diamondTiles are squares turned 45 degrees, size is the distance between opposite corners
+func diamondTiles(width, height int, size float64) []tile {
+	var tiles []tile
+	r := size / math.Sqrt2
+	for row := 0; float64(row)*r < float64(height)+r; row++ {
+		y := float64(row) * r
+		for x := r * float64(row%2); x < float64(width)+size; x += 2 * r {
+			tiles = append(tiles, tile{x, y, regularPolygon(4, x, y, r, math.Pi/4)})
+		}
+	}
+	return tiles
+}
+
+// squareTiles are squares size on a side
+func squareTiles(width, height int, size float64) []tile {
+	var tiles []tile
+	for y := size / 2; y < float64(height)+size; y += size {
+		for x := size / 2; x < float64(width)+size; x += size {
+			tiles = append(tiles, tile{x, y, regularPolygon(4, x, y, size/math.Sqrt2, 0)})
+		}
+	}
+	return tiles
+}
+
+// hexTiles are flat topped hexagons size tall, every other column shifted down by half a hexagon
+func hexTiles(width, height int, size float64) []tile {
+	var tiles []tile
+	r := size / math.Sqrt(3)
+	for col := 0; float64(col)*1.5*r < float64(width)+2*r; col++ {
+		x := float64(col) * 1.5 * r
+		for y := size / 2 * float64(col%2); y < float64(height)+size; y += size {
+			tiles = append(tiles, tile{x, y, regularPolygon(6, x, y, r, 0)})
+		}
+	}
+	return tiles
+}
+
+// triangleTiles are equilateral triangles size tall, pointing up and down in turn along each row
+func triangleTiles(width, height int, size float64) []tile {
+	var tiles []tile
+	half := size / math.Sqrt(3)
+	for row := 0; float64(row)*size < float64(height); row++ {
+		top := float64(row) * size
+		bottom := top + size
+		for i := 0; float64(i-1)*half < float64(width); i++ {
+			x := float64(i) * half
+			if (i+row)%2 == 0 {
+				tiles = append(tiles, tile{x, top + 2*size/3, []gg.Point{{X: x, Y: top}, {X: x + half, Y: bottom}, {X: x - half, Y: bottom}}})
+			} else {
+				tiles = append(tiles, tile{x, top + size/3, []gg.Point{{X: x - half, Y: top}, {X: x + half, Y: top}, {X: x, Y: bottom}}})
+			}
+		}
+	}
+	return tiles
+}
+
+// robinson is half of a penrose rhombus, thin rhombi are made of two kind 0 and thick of two kind 1
+// A is the apex and BC the edge shared with the other half.
+type robinson struct {
+	kind    int
+	a, b, c gg.Point
+}
+
+// penroseTiles are penrose rhombi with edges about size long
+// A wheel of robinson triangles big enough to cover the canvas is split until the edges are short enough, then the
+// halves are paired back up into rhombi. Halves cut off by the wheel's rim stay as triangles.
+func penroseTiles(width, height int, size float64) []tile {
+	phi := (1 + math.Sqrt(5)) / 2
+	cx, cy := float64(width)/2, float64(height)/2
+	radius := math.Hypot(cx, cy) / math.Cos(math.Pi/10)
+
+	var triangles []robinson
+	for i := 0; i < 10; i++ {
+		b := gg.Point{X: cx + radius*math.Cos(float64(2*i-1)*math.Pi/10), Y: cy + radius*math.Sin(float64(2*i-1)*math.Pi/10)}
+		c := gg.Point{X: cx + radius*math.Cos(float64(2*i+1)*math.Pi/10), Y: cy + radius*math.Sin(float64(2*i+1)*math.Pi/10)}
+		if i%2 == 0 {
+			b, c = c, b
+		}
+		triangles = append(triangles, robinson{0, gg.Point{X: cx, Y: cy}, b, c})
+	}
+
+	lerp := func(p, q gg.Point, t float64) gg.Point {
+		return gg.Point{X: p.X + (q.X-p.X)*t, Y: p.Y + (q.Y-p.Y)*t}
+	}
+	for edge := radius; edge > size; edge /= phi {
+		next := make([]robinson, 0, 3*len(triangles))
+		for _, t := range triangles {
+			if t.kind == 0 {
+				p := lerp(t.a, t.b, 1/phi)
+				next = append(next, robinson{0, t.c, p, t.b}, robinson{1, p, t.c, t.a})
+			} else {
+				q := lerp(t.b, t.a, 1/phi)
+				r := lerp(t.b, t.c, 1/phi)
+				next = append(next, robinson{1, r, t.c, t.a}, robinson{1, q, r, t.b}, robinson{0, r, q, t.a})
+			}
+		}
+		triangles = next
+	}
+
+	// pair halves on their shared edge, rounding so both halves find the same key
+	type key [4]int64
+	round := func(p gg.Point) (int64, int64) {
+		return int64(math.Round(p.X * 1000)), int64(math.Round(p.Y * 1000))
+	}
+	edgeKey := func(t robinson) key {
+		bx, by := round(t.b)
+		ux, uy := round(t.c)
+		if bx > ux || (bx == ux && by > uy) {
+			bx, by, ux, uy = ux, uy, bx, by
+		}
+		return key{bx, by, ux, uy}
+	}
+	partner := map[key]int{}
+	var tiles []tile
+	for i, t := range triangles {
+		k := edgeKey(t)
+		j, ok := partner[k]
+		if !ok {
+			partner[k] = i
+			continue
+		}
+		delete(partner, k)
+		o := triangles[j]
+		tiles = append(tiles, tile{(t.b.X + t.c.X) / 2, (t.b.Y + t.c.Y) / 2, []gg.Point{t.a, t.b, o.a, t.c}})
+	}
+	// walk the triangles again rather than the map so the order doesn't change between runs
+	for i, t := range triangles {
+		if j, ok := partner[edgeKey(t)]; ok && j == i {
+			tiles = append(tiles, tile{(t.a.X + t.b.X + t.c.X) / 3, (t.a.Y + t.b.Y + t.c.Y) / 3, []gg.Point{t.a, t.b, t.c}})
+		}
+	}
+	return tiles
+}
+
+// voronoiTiles are the voronoi cells of one random point in each size by size square
+// Each cell starts as a square around its point and is cut down by the bisector with each nearby point.
+func voronoiTiles(width, height int, size float64, r *rand.Rand) []tile {
+	cols := int(math.Ceil(float64(width)/size)) + 2
+	rows := int(math.Ceil(float64(height)/size)) + 2
+	// points are in a grid one cell bigger than the canvas all round
+	points := make([]gg.Point, cols*rows)
+	for j := 0; j < rows; j++ {
+		for i := 0; i < cols; i++ {
+			points[j*cols+i] = gg.Point{X: (float64(i-1) + r.Float64()) * size, Y: (float64(j-1) + r.Float64()) * size}
+		}
+	}
+
+	var tiles []tile
+	for j := 0; j < rows; j++ {
+		for i := 0; i < cols; i++ {
+			p := points[j*cols+i]
+			cell := []gg.Point{
+				{X: p.X - 2*size, Y: p.Y - 2*size},
+				{X: p.X + 2*size, Y: p.Y - 2*size},
+				{X: p.X + 2*size, Y: p.Y + 2*size},
+				{X: p.X - 2*size, Y: p.Y + 2*size},
+			}
+			for dj := -2; dj <= 2; dj++ {
+				for di := -2; di <= 2; di++ {
+					ni, nj := i+di, j+dj
+					if (di == 0 && dj == 0) || ni < 0 || nj < 0 || ni >= cols || nj >= rows {
+						continue
+					}
+					cell = clipCloser(cell, p, points[nj*cols+ni])
+				}
+			}
+			if len(cell) >= 3 {
+				tiles = append(tiles, tile{p.X, p.Y, cell})
+			}
+		}
+	}
+	return tiles
+}
+
+// clipCloser cuts a convex polygon down to the part that is closer to p than to q
+func clipCloser(poly []gg.Point, p, q gg.Point) []gg.Point {
+	mx, my := (p.X+q.X)/2, (p.Y+q.Y)/2
+	dx, dy := q.X-p.X, q.Y-p.Y
+	side := func(v gg.Point) float64 {
+		return (v.X-mx)*dx + (v.Y-my)*dy
+	}
+
+	var out []gg.Point
+	for k, v := range poly {
+		w := poly[(k+1)%len(poly)]
+		sv, sw := side(v), side(w)
+		if sv <= 0 {
+			out = append(out, v)
+		}
+		if (sv < 0 && sw > 0) || (sv > 0 && sw < 0) {
+			t := sv / (sv - sw)
+			out = append(out, gg.Point{X: v.X + (w.X-v.X)*t, Y: v.Y + (w.Y-v.Y)*t})
+		}
+	}
+	return out
+}
+
+// contains is true when a point is inside the tile or on its edge, whichever way the corners wind
+func (t tile) contains(x, y float64) bool {
+	var pos, neg bool
+	for k, p := range t.points {
+		q := t.points[(k+1)%len(t.points)]
+		cross := (q.X-p.X)*(y-p.Y) - (q.Y-p.Y)*(x-p.X)
+		pos = pos || cross > 0
+		neg = neg || cross < 0
+	}
+	return !(pos && neg)
+}
+
+// maskNudgeX and maskNudgeY move the point a mask samples off the pixel center, which often lands exactly on an edge
+// shared by two tiles, in a direction no tiling's edges run along, so the point falls inside exactly one of them
+const (
+	maskNudgeX = 1e-6
+	maskNudgeY = 1.3e-6
+)
+
+// mask covers the pixels in r whose centers are inside the tile
+// Pixels are either fully in or out, never partly covered, so neighbouring masks meet without blending or gaps.
+func (t tile) mask(r image.Rectangle) *image.Alpha {
+	mask := image.NewAlpha(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if t.contains(float64(x)+0.5+maskNudgeX, float64(y)+0.5+maskNudgeY) {
+				mask.SetAlpha(x, y, color.Alpha{255})
+			}
+		}
+	}
+	return mask
+}
+
+// edgeDistance is how far a point is from the nearest edge of the tile
+func (t tile) edgeDistance(x, y float64) float64 {
+	d := math.Inf(1)
+	for k, p := range t.points {
+		q := t.points[(k+1)%len(t.points)]
+		dx, dy := q.X-p.X, q.Y-p.Y
+		u := 0.0
+		if l := dx*dx + dy*dy; l > 0 {
+			u = math.Max(0, math.Min(1, ((x-p.X)*dx+(y-p.Y)*dy)/l))
+		}
+		d = math.Min(d, math.Hypot(x-p.X-u*dx, y-p.Y-u*dy))
+	}
+	return d
+}
@@ -0,0 +1,35 @@
+package sketch
+
+import (
+	"fmt"
+)
+
+// checkChoice rejects a value that isn't one of the options, naming the kind of value and listing the options
+func checkChoice(kind, value string, options []string) error {
+	for _, o := range options {
+		if o == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown %s %q, use one of %v", kind, value, options)
+}
+
+// checkOptionalChoice is checkChoice for values where leaving them empty picks the sketch's default
+func checkOptionalChoice(kind, value string, options []string) error {
+	if value == "" {
+		return nil
+	}
+	return checkChoice(kind, value, options)
+}
+
+// checkCount rejects a count that is negative or more than one for each pixel of the canvas
+// Counts of things placed on the canvas are held to its size, so a large count can't outgrow the memory the canvas uses.
+func checkCount(kind string, n, width, height int) error {
+	if n < 0 || n > width*height {
+		return fmt.Errorf("%s must be between 0 and %d, one for each pixel", kind, width*height)
+	}
+	return nil
+}
+
+// maxSides is the most sides a polygon can have, well past where it looks like a circle
+const maxSides = 1000
@@ -0,0 +1,132 @@
+package sketch
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCheckChoice(t *testing.T) {
+	options := []string{"straight", "drift"}
+	for _, c := range []struct {
+		value    string
+		optional bool
+		ok       bool
+	}{
+		{"straight", false, true},
+		{"drift", true, true},
+		{"curly", false, false},
+		{"curly", true, false},
+		{"", false, false},
+		{"", true, true},
+		{"Straight", false, false},
+	} {
+		check := checkChoice
+		if c.optional {
+			check = checkOptionalChoice
+		}
+		err := check("curve", c.value, options)
+		if (err == nil) != c.ok {
+			t.Errorf("%q optional %v: got %v, want ok %v", c.value, c.optional, err, c.ok)
+		}
+		if err != nil && err.Error() != `unknown curve "`+c.value+`", use one of [straight drift]` {
+			t.Errorf("%q: unexpected message %q", c.value, err)
+		}
+	}
+}
+
+// TestValidate breaks one field of each sketch's default params at a time, the defaults themselves must pass
+func TestValidate(t *testing.T) {
+	type validator interface {
+		Validate() error
+	}
+	cases := map[string]map[string]func(p interface{}){
+		"anderson": {
+			"horizon":        func(p interface{}) { p.(*AndersonParams).Horizon = 1 },
+			"slots":          func(p interface{}) { p.(*AndersonParams).Slots = 101 },
+			"ripple spacing": func(p interface{}) { p.(*AndersonParams).RippleSpacing = 0 },
+			"left edge":      func(p interface{}) { p.(*AndersonParams).LeftEdge = -1 },
+		},
+		"crack": {
+			"curve":        func(p interface{}) { p.(*CrackParams).Curve = "curly" },
+			"crack limit":  func(p interface{}) { p.(*CrackParams).CrackLimit = 100*100 + 1 },
+			"seeds":        func(p interface{}) { p.(*CrackParams).Seeds = -1 },
+			"step":         func(p interface{}) { p.(*CrackParams).StepLength = 0 },
+			"sand alpha":   func(p interface{}) { p.(*CrackParams).SandAlpha = 256 },
+			"blend":        func(p interface{}) { p.(*CrackParams).Blend = "burn" },
+			"edge density": func(p interface{}) { p.(*CrackParams).EdgeSource, p.(*CrackParams).EdgeDensity = testSource(4, 4), 0 },
+			"gamma":        func(p interface{}) { p.(*CrackParams).HDROptions = HDROptions{HDR: true} },
+		},
+		"crawl": {
+			"collision":    func(p interface{}) { p.(*CrawlParams).Collision = "bounce" },
+			"count":        func(p interface{}) { p.(*CrawlParams).Count = 100*100 + 1 },
+			"branch":       func(p interface{}) { p.(*CrawlParams).BranchChance = 2 },
+			"start":        func(p interface{}) { p.(*CrawlParams).Start = "middle" },
+			"empty points": func(p interface{}) { p.(*CrawlParams).Start = "points" },
+			"point left": func(p interface{}) {
+				p.(*CrawlParams).Start, p.(*CrawlParams).Points = "points", [][2]float64{{-1, 50}}
+			},
+			"point below": func(p interface{}) {
+				p.(*CrawlParams).Start, p.(*CrawlParams).Points = "points", [][2]float64{{50, 50}, {50, 100}}
+			},
+			"point nan":   func(p interface{}) { p.(*CrawlParams).Points = [][2]float64{{math.NaN(), 50}} },
+			"edge":        func(p interface{}) { p.(*CrawlParams).Edge = "bounce" },
+			"image field": func(p interface{}) { p.(*CrawlParams).Field = "image" },
+		},
+		"firework": {
+			"gravity":   func(p interface{}) { p.(*FireworkParams).Gravity = 0 },
+			"particles": func(p interface{}) { p.(*FireworkParams).Shells, p.(*FireworkParams).Particles = 100, 101 },
+			"drag":      func(p interface{}) { p.(*FireworkParams).Drag = 1 },
+			"life":      func(p interface{}) { p.(*FireworkParams).Life = 0 },
+			"gamma":     func(p interface{}) { p.(*FireworkParams).HDROptions = HDROptions{HDR: true} },
+		},
+		"flip": {
+			"tiling": func(p interface{}) { p.(*flipRenderParams).Tiling = "star" },
+			"mirror": func(p interface{}) { p.(*flipRenderParams).MirrorChance = 1.5 },
+			"scale": func(p interface{}) {
+				p.(*flipRenderParams).ScaleChance, p.(*flipRenderParams).Scale = 0.1, 0
+			},
+			"margin": func(p interface{}) { p.(*flipRenderParams).Margin = -1 },
+			"anchor": func(p interface{}) { p.(*flipRenderParams).Anchor = "middle" },
+		},
+		"grid": {
+			"shape":          func(p interface{}) { p.(*GridParams).Shape = "star" },
+			"sides":          func(p interface{}) { p.(*GridParams).Shape, p.(*GridParams).Sides = "polygon", 2 },
+			"too many sides": func(p interface{}) { p.(*GridParams).Shape, p.(*GridParams).Sides = "polygon", maxSides+1 },
+			"lattice":        func(p interface{}) { p.(*GridParams).Lattice = "triangle" },
+			"vignette":       func(p interface{}) { p.(*GridParams).Vignette = "square" },
+			"size":           func(p interface{}) { p.(*GridParams).Size = 0.5 },
+			"crop":           func(p interface{}) { p.(*GridParams).Crop = [4]float64{-0.1, 0, 0, 0} },
+			"crop all":       func(p interface{}) { p.(*GridParams).Crop = [4]float64{0, 0.5, 0, 0.5} },
+		},
+		"growth": {
+			"metric":         func(p interface{}) { p.(*GrowthParams).Metric = "hexagonal" },
+			"starting seeds": func(p interface{}) { p.(*GrowthParams).StartingSeeds = 100*100 + 1 },
+			"speed variance": func(p interface{}) { p.(*GrowthParams).SpeedVariance = 1 },
+		},
+		"layer": {
+			"path min": func(p interface{}) { p.(*LayerParams).PathMin = 0 },
+			"edges":    func(p interface{}) { p.(*LayerParams).MaxEdgeCount = maxSides + 1 },
+			"fit":      func(p interface{}) { p.(*LayerParams).Fit = "fill" },
+			"gamma":    func(p interface{}) { p.(*LayerParams).HDROptions = HDROptions{HDR: true} },
+		},
+		"spiral": {
+			"family":        func(p interface{}) { p.(*SpiralParams).Family = "golden" },
+			"arms":          func(p interface{}) { p.(*SpiralParams).Arms = 0 },
+			"too many arms": func(p interface{}) { p.(*SpiralParams).Arms = 100*100 + 1 },
+			"step":          func(p interface{}) { p.(*SpiralParams).Step = -1 },
+		},
+	}
+
+	for name, breaks := range cases {
+		if err := Renderers[name].Params(100, 100, nil).(validator).Validate(); err != nil {
+			t.Errorf("%s defaults: %v", name, err)
+		}
+		for field, breakIt := range breaks {
+			p := Renderers[name].Params(100, 100, nil)
+			breakIt(p)
+			if err := p.(validator).Validate(); err == nil {
+				t.Errorf("%s %s: expected params to be rejected", name, field)
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package util
+
+import "math"
+
+// D65 white point, the reference white of sRGB
+const (
+	whiteX = 0.95047
+	whiteY = 1.0
+	whiteZ = 1.08883
+)
+
+// RGBToLab converts 0-255 sRGB to CIE L*a*b*, where equal distances look about equally different
+func RGBToLab(r, g, b float64) (l, a, bb float64) {
+	rl, gl, bl := toLinear(r/255), toLinear(g/255), toLinear(b/255)
+	x := (0.4124564*rl + 0.3575761*gl + 0.1804375*bl) / whiteX
+	y := (0.2126729*rl + 0.7151522*gl + 0.0721750*bl) / whiteY
+	z := (0.0193339*rl + 0.1191920*gl + 0.9503041*bl) / whiteZ
+	fx, fy, fz := labF(x), labF(y), labF(z)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// LabToRGB converts CIE L*a*b* to 0-255 sRGB, clamping colors outside of sRGB
+func LabToRGB(l, a, bb float64) (r, g, b float64) {
+	fy := (l + 16) / 116
+	fx := fy + a/500
+	fz := fy - bb/200
+	x, y, z := labFInv(fx)*whiteX, labFInv(fy)*whiteY, labFInv(fz)*whiteZ
+	rl := 3.2404542*x - 1.5371385*y - 0.4985314*z
+	gl := -0.9692660*x + 1.8760108*y + 0.0415560*z
+	bl := 0.0556434*x - 0.2040259*y + 1.0572252*z
+	return fromLinear(rl) * 255, fromLinear(gl) * 255, fromLinear(bl) * 255
+}
+
+func toLinear(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func fromLinear(c float64) float64 {
+	c = math.Max(0, math.Min(1, c))
+	if c <= 0.0031308 {
+		return 12.92 * c
+	}
+	return 1.055*math.Pow(c, 1/2.4) - 0.055
+}
+
+// labF is the cube root with a linear piece near black, where the cube root is too steep
+func labF(t float64) float64 {
+	if t > 216.0/24389 {
+		return math.Cbrt(t)
+	}
+	return (24389.0/27*t + 16) / 116
+}
+
+func labFInv(t float64) float64 {
+	if t*t*t > 216.0/24389 {
+		return t * t * t
+	}
+	return (116*t - 16) * 27 / 24389
+}
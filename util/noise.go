@@ -0,0 +1,69 @@
+package util
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Noise is 2d Perlin gradient noise, with its permutation drawn from a random source
+type Noise struct {
+	perm [512]int
+}
+
+// NewNoise creates a noise field, a seeded source makes it repeatable
+func NewNoise(r *rand.Rand) *Noise {
+	n := &Noise{}
+	p := r.Perm(256)
+	for i := 0; i < 512; i++ {
+		n.perm[i] = p[i%256]
+	}
+	return n
+}
+
+// At returns the noise at x, y, roughly between -1 and 1 and changing over a distance of about 1
+func (n *Noise) At(x, y float64) float64 {
+	fx, fy := math.Floor(x), math.Floor(y)
+	xi, yi := int(fx)&255, int(fy)&255
+	x -= fx
+	y -= fy
+	u, v := fade(x), fade(y)
+
+	aa := n.perm[n.perm[xi]+yi]
+	ab := n.perm[n.perm[xi]+yi+1]
+	ba := n.perm[n.perm[xi+1]+yi]
+	bb := n.perm[n.perm[xi+1]+yi+1]
+
+	return lerp(v,
+		lerp(u, grad(aa, x, y), grad(ba, x-1, y)),
+		lerp(u, grad(ab, x, y-1), grad(bb, x-1, y-1)))
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+// grad picks one of eight directions from the hash and dots it with x, y
+func grad(hash int, x, y float64) float64 {
+	switch hash & 7 {
+	case 0:
+		return x + y
+	case 1:
+		return -x + y
+	case 2:
+		return x - y
+	case 3:
+		return -x - y
+	case 4:
+		return x
+	case 5:
+		return -x
+	case 6:
+		return y
+	default:
+		return -y
+	}
+}
@@ -65,23 +65,26 @@ func Rgb255(c color.Color) (r, g, b int) {
 }
 
 // RandRange returns an int between -max and max
-func RandRange(max int) int {
-	return -max + rand.Intn(2*max)
+func RandRange(r *rand.Rand, max int) int {
+	if max <= 0 {
+		return 0
+	}
+	return -max + r.Intn(2*max)
 }
 
 // RandFloat64Range returns a float64 between -max and max
-func RandFloat64Range(max float64) float64 {
-	return -max + rand.Float64()*2*max
+func RandFloat64Range(r *rand.Rand, max float64) float64 {
+	return -max + r.Float64()*2*max
 }
 
 // RandFloat64RangeFrom returns a float64 between min and max
-func RandFloat64RangeFrom(min, max float64) float64 {
-	return min + rand.Float64()*(max-min)
+func RandFloat64RangeFrom(r *rand.Rand, min, max float64) float64 {
+	return min + r.Float64()*(max-min)
 }
 
 // RandIntRangeFrom returns an int between min and max
-func RandIntRangeFrom(min, max int) int {
-	return min + rand.Intn(max-min)
+func RandIntRangeFrom(r *rand.Rand, min, max int) int {
+	return min + r.Intn(max-min)
 }
 
 // MaxInt returns the larger of two ints
@@ -115,3 +118,4 @@ func MinFloat64(a, b float64) float64 {
 	}
 	return b
 }
+